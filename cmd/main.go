@@ -11,9 +11,39 @@ func main() {
 	framework.NewServer().
 		BindFlags(pflag.CommandLine).
 		RegisterBackupItemAction("superphenix.net/backup-virtualmachine", vmBackup).
+		RegisterBackupItemAction("superphenix.net/backup-virtualmachineinstance", vmiBackup).
+		RegisterRestoreItemActionV2("superphenix.net/restore-virtualmachine", vmRestore).
+		RegisterRestoreItemAction("superphenix.net/restore-virtualmachineinstance", vmiRestore).
+		RegisterRestoreItemAction("superphenix.net/restore-virt-launcher-pod", launcherPodRestore).
+		RegisterRestoreItemAction("superphenix.net/restore-kubeovn-ip", ipRestore).
+		RegisterRestoreItemAction("superphenix.net/restore-kubeovn-nat", natRestore).
 		Serve()
 }
 
 func vmBackup(logger logrus.FieldLogger) (interface{}, error) {
 	return plugin.NewVMBackupItemAction(logger), nil
 }
+
+func vmiBackup(logger logrus.FieldLogger) (interface{}, error) {
+	return plugin.NewVMIBackupItemAction(logger), nil
+}
+
+func vmRestore(logger logrus.FieldLogger) (interface{}, error) {
+	return plugin.NewVMRestoreItemAction(logger), nil
+}
+
+func vmiRestore(logger logrus.FieldLogger) (interface{}, error) {
+	return plugin.NewVMIRestoreItemAction(logger), nil
+}
+
+func launcherPodRestore(logger logrus.FieldLogger) (interface{}, error) {
+	return plugin.NewLauncherPodRestoreItemAction(logger), nil
+}
+
+func ipRestore(logger logrus.FieldLogger) (interface{}, error) {
+	return plugin.NewIPRestoreItemAction(logger), nil
+}
+
+func natRestore(logger logrus.FieldLogger) (interface{}, error) {
+	return plugin.NewNATRestoreItemAction(logger), nil
+}
@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+// subnetsResource is the cluster-scoped resource of the Kube-OVN subnets
+var subnetsResource = schema.GroupResource{Group: "kubeovn.io", Resource: "subnets"}
+
+// vpcsResource is the cluster-scoped resource of the Kube-OVN VPCs
+var vpcsResource = schema.GroupResource{Group: "kubeovn.io", Resource: "vpcs"}
+
+// vpcNatGatewaysResource is the cluster-scoped resource of the Kube-OVN VPC NAT gateways
+var vpcNatGatewaysResource = schema.GroupResource{Group: "kubeovn.io", Resource: "vpc-nat-gateways"}
+
+// ovnEipsResource, ovnFipsResource and ovnDnatRulesResource are the cluster-scoped resources of the OVN NAT rules
+var (
+	ovnEipsResource      = schema.GroupResource{Group: "kubeovn.io", Resource: "ovn-eips"}
+	ovnFipsResource      = schema.GroupResource{Group: "kubeovn.io", Resource: "ovn-fips"}
+	ovnDnatRulesResource = schema.GroupResource{Group: "kubeovn.io", Resource: "ovn-dnat-rules"}
+)
+
+// iptablesEipsResource, iptablesFipRulesResource and iptablesDnatRulesResource are the cluster-scoped resources of
+// the NAT rules of the VPC NAT gateways
+var (
+	iptablesEipsResource      = schema.GroupResource{Group: "kubeovn.io", Resource: "iptables-eips"}
+	iptablesFipRulesResource  = schema.GroupResource{Group: "kubeovn.io", Resource: "iptables-fip-rules"}
+	iptablesDnatRulesResource = schema.GroupResource{Group: "kubeovn.io", Resource: "iptables-dnat-rules"}
+)
+
+// securityGroupsResource is the cluster-scoped resource of the Kube-OVN security groups
+var securityGroupsResource = schema.GroupResource{Group: "kubeovn.io", Resource: "security-groups"}
+
+// qosPoliciesResource is the cluster-scoped resource of the Kube-OVN QoS policies
+var qosPoliciesResource = schema.GroupResource{Group: "kubeovn.io", Resource: "qos-policies"}
+
+// nadsResource is the resource of the Multus NetworkAttachmentDefinitions
+var nadsResource = schema.GroupResource{Group: "k8s.cni.cncf.io", Resource: "network-attachment-definitions"}
+
+// backupAdditionalItems returns the network objects a VM or VMI depends on, given the identity of its interfaces, its
+// networks, name and namespace, and the annotations persisting the settings of its interfaces, so that they are backed
+// up along with it
+func backupAdditionalItems(netInfos []u.NetInfo, networks []kvcore.Network, name, namespace string, annotations map[string]string) ([]velero.ResourceIdentifier, error) {
+	subnets := u.SubnetNamesOfNetInfos(netInfos)
+
+	// The addresses of subnets of custom VPCs only make sense inside their VPC
+	topology, err := u.GetVPCTopology(subnets)
+	if err != nil {
+		return nil, err
+	}
+
+	// The NADs referenced by the networks must be restored along with the VM
+	nads, err := u.NADsOfNetworks(networks, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// The public addresses of the VM are bound to its addresses by FIP and DNAT rules
+	bindings, err := u.GetNATBindings(netInfos, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bandwidth tiers of the NAT gateways and public addresses are enforced by QoS policies
+	qosPolicies, err := u.GetQoSPolicies(topology.NATGateways, bindings.IptablesEIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	var identifiers []velero.ResourceIdentifier
+	identifiers = append(identifiers, clusterResourceIdentifiers(qosPoliciesResource, qosPolicies)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(vpcsResource, topology.VPCs)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(vpcNatGatewaysResource, topology.NATGateways)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(subnetsResource, append(subnets, topology.Subnets...))...)
+	identifiers = append(identifiers, nadResourceIdentifiers(nads)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(securityGroupsResource, u.SecurityGroupsOfAnnotations(annotations))...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(ovnEipsResource, bindings.OvnEIPs)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(ovnFipsResource, bindings.OvnFIPs)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(ovnDnatRulesResource, bindings.OvnDNATRules)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(iptablesEipsResource, bindings.IptablesEIPs)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(iptablesFipRulesResource, bindings.IptablesFIPRules)...)
+	identifiers = append(identifiers, clusterResourceIdentifiers(iptablesDnatRulesResource, bindings.IptablesDNATRules)...)
+
+	return identifiers, nil
+}
+
+// clusterResourceIdentifiers returns the additional items to back up for cluster-scoped objects of a resource
+func clusterResourceIdentifiers(resource schema.GroupResource, names []string) []velero.ResourceIdentifier {
+	var identifiers []velero.ResourceIdentifier
+	for _, name := range names {
+		identifiers = append(identifiers, velero.ResourceIdentifier{
+			GroupResource: resource,
+			Name:          name,
+		})
+	}
+
+	return identifiers
+}
+
+// nadResourceIdentifiers returns the additional items to back up for the NADs of a VM, so that the restored VM doesn't
+// reference a missing attachment even if the resource filters of the backup exclude them
+func nadResourceIdentifiers(nads []types.NamespacedName) []velero.ResourceIdentifier {
+	var identifiers []velero.ResourceIdentifier
+	for _, nad := range nads {
+		identifiers = append(identifiers, velero.ResourceIdentifier{
+			GroupResource: nadsResource,
+			Namespace:     nad.Namespace,
+			Name:          nad.Name,
+		})
+	}
+
+	return identifiers
+}
@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+func TestBackupAdditionalItems(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := u.GetKubeOvnClient
+	defer func() { u.GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	fakeClient := fake.NewSimpleClientset()
+	for _, subnet := range []*kubeovnv1.Subnet{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ovn-default"}, Spec: kubeovnv1.SubnetSpec{Vpc: "ovn-cluster"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant-subnet"}, Spec: kubeovnv1.SubnetSpec{Vpc: "tenant"}},
+	} {
+		_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), subnet, metav1.CreateOptions{})
+	}
+	_, _ = fakeClient.KubeovnV1().VpcNatGateways().Create(context.Background(), &kubeovnv1.VpcNatGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-gw"},
+		Spec:       kubeovnv1.VpcNatGatewaySpec{Vpc: "tenant", Subnet: "tenant-subnet", ExternalSubnets: []string{"external"}, QoSPolicy: "gw-tier"},
+	}, metav1.CreateOptions{})
+	_, _ = fakeClient.KubeovnV1().IptablesFIPRules().Create(context.Background(), &kubeovnv1.IptablesFIPRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-fip"},
+		Spec:       kubeovnv1.IptablesFIPRuleSpec{EIP: "tenant-eip", InternalIP: "192.168.0.10"},
+	}, metav1.CreateOptions{})
+	_, _ = fakeClient.KubeovnV1().IptablesEIPs().Create(context.Background(), &kubeovnv1.IptablesEIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-eip"},
+		Spec:       kubeovnv1.IptablesEIPSpec{NatGwDp: "tenant-gw", QoSPolicy: "eip-tier"},
+	}, metav1.CreateOptions{})
+	u.GetKubeOvnClient = func() (u.KubeOvnClient, error) {
+		return fakeClient, nil
+	}
+
+	tests := []struct {
+		name        string
+		netInfos    []u.NetInfo
+		networks    []kvcore.Network
+		annotations map[string]string
+		want        []velero.ResourceIdentifier
+		wantErr     bool
+	}{
+		{
+			name: "default VPC",
+			netInfos: []u.NetInfo{
+				{NADAnnotation: "ovn.kubernetes.io", Subnet: "ovn-default"},
+			},
+			want: []velero.ResourceIdentifier{
+				{GroupResource: subnetsResource, Name: "ovn-default"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "custom VPC attached through a NAD",
+			netInfos: []u.NetInfo{
+				{NADAnnotation: "ovn.kubernetes.io", Subnet: "ovn-default"},
+				{NADAnnotation: "tenant-nad.test-ns.ovn.kubernetes.io", IPs: "192.168.0.10", Subnet: "tenant-subnet", Source: u.NetInfoSourceVMIStatus},
+			},
+			networks: []kvcore.Network{
+				{Name: "pod", NetworkSource: kvcore.NetworkSource{Pod: &kvcore.PodNetwork{}}},
+				{Name: "tenant", NetworkSource: kvcore.NetworkSource{Multus: &kvcore.MultusNetwork{NetworkName: "test-ns/tenant-nad"}}},
+			},
+			annotations: map[string]string{
+				"ovn.kubernetes.io/security_groups":                    "sg-web,sg-ssh",
+				"tenant-nad.test-ns.ovn.kubernetes.io/security_groups": "sg-ssh",
+			},
+			want: []velero.ResourceIdentifier{
+				{GroupResource: qosPoliciesResource, Name: "eip-tier"},
+				{GroupResource: qosPoliciesResource, Name: "gw-tier"},
+				{GroupResource: vpcsResource, Name: "tenant"},
+				{GroupResource: vpcNatGatewaysResource, Name: "tenant-gw"},
+				{GroupResource: subnetsResource, Name: "ovn-default"},
+				{GroupResource: subnetsResource, Name: "tenant-subnet"},
+				{GroupResource: subnetsResource, Name: "external"},
+				{GroupResource: nadsResource, Namespace: "test-ns", Name: "tenant-nad"},
+				{GroupResource: securityGroupsResource, Name: "sg-ssh"},
+				{GroupResource: securityGroupsResource, Name: "sg-web"},
+				{GroupResource: iptablesEipsResource, Name: "tenant-eip"},
+				{GroupResource: iptablesFipRulesResource, Name: "tenant-fip"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid network name",
+			networks: []kvcore.Network{
+				{Name: "tenant", NetworkSource: kvcore.NetworkSource{Multus: &kvcore.MultusNetwork{NetworkName: "a/b/c"}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := backupAdditionalItems(tt.netInfos, tt.networks, "test-vm", "test-ns", tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("backupAdditionalItems() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("backupAdditionalItems() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
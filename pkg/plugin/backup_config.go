@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"fmt"
+
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+// persistedIdentityAnnotation selects how the backup treats the identity persisted on the VMs whose Kube-OVN IP is missing
+const persistedIdentityAnnotation = "superphenix.net/persisted-identity"
+
+// persistedIdentityMode describes how the backup treats the identity persisted on a VM by a previous backup or restore,
+// for the interfaces whose Kube-OVN IP is missing
+type persistedIdentityMode string
+
+const (
+	// persistedIdentityFallback only uses the persisted identity if the VMI doesn't report the interface
+	persistedIdentityFallback persistedIdentityMode = "fallback"
+	// persistedIdentityAuthoritative prefers the persisted identity over the interfaces reported by the VMI,
+	// and keeps its annotations unchanged
+	persistedIdentityAuthoritative persistedIdentityMode = "authoritative"
+)
+
+// backupConfig holds the settings of a backup. They are read from the annotations of the Velero Backup,
+// so that every backup can be tuned independently.
+type backupConfig struct {
+	persistedIdentity persistedIdentityMode
+}
+
+// newBackupConfig reads the settings of a backup from its annotations, falling back to the defaults
+func newBackupConfig(backup *velerov1api.Backup) (*backupConfig, error) {
+	config := &backupConfig{
+		persistedIdentity: persistedIdentityFallback,
+	}
+
+	if value, ok := backup.Annotations[persistedIdentityAnnotation]; ok {
+		mode, err := parsePersistedIdentityMode(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", persistedIdentityAnnotation, err)
+		}
+		config.persistedIdentity = mode
+	}
+
+	return config, nil
+}
+
+// trustPersisted tells whether the identity persisted on the VMs is authoritative
+func (c *backupConfig) trustPersisted() bool {
+	return c.persistedIdentity == persistedIdentityAuthoritative
+}
+
+// netInfosToPersist returns the NetInfos whose annotations the backup writes on the VM or VMI.
+// Authoritative persisted identities are left out, so that their annotations are kept as they are.
+func (c *backupConfig) netInfosToPersist(netInfos []u.NetInfo) []u.NetInfo {
+	if !c.trustPersisted() {
+		return netInfos
+	}
+
+	var toPersist []u.NetInfo
+	for _, netInfo := range netInfos {
+		if netInfo.Source != u.NetInfoSourceAnnotations {
+			toPersist = append(toPersist, netInfo)
+		}
+	}
+
+	return toPersist
+}
+
+// parsePersistedIdentityMode translates the value of an annotation into a persistedIdentityMode
+func parsePersistedIdentityMode(value string) (persistedIdentityMode, error) {
+	switch mode := persistedIdentityMode(value); mode {
+	case persistedIdentityFallback, persistedIdentityAuthoritative:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("expected one of %s or %s, got %s", persistedIdentityFallback, persistedIdentityAuthoritative, value)
+	}
+}
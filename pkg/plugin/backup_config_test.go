@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewBackupConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        backupConfig
+		wantErr     bool
+	}{
+		{
+			name:        "defaults",
+			annotations: nil,
+			want: backupConfig{
+				persistedIdentity: persistedIdentityFallback,
+			},
+			wantErr: false,
+		},
+		{
+			name: "authoritative persisted identity",
+			annotations: map[string]string{
+				persistedIdentityAnnotation: "authoritative",
+			},
+			want: backupConfig{
+				persistedIdentity: persistedIdentityAuthoritative,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid persisted identity mode",
+			annotations: map[string]string{
+				persistedIdentityAnnotation: "ignore",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := &velerov1api.Backup{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tt.annotations,
+				},
+			}
+
+			got, err := newBackupConfig(backup)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newBackupConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if *got != tt.want {
+				t.Errorf("newBackupConfig() got = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupConfigNetInfosToPersist(t *testing.T) {
+	netInfos := []u.NetInfo{
+		{NADAnnotation: "ovn.kubernetes.io", MAC: "00:00:00:00:00:01", Source: u.NetInfoSourceIP},
+		{NADAnnotation: "nad1.test-ns.ovn.kubernetes.io", MAC: "00:00:00:00:00:02", Source: u.NetInfoSourceVMIStatus},
+		{NADAnnotation: "nad2.test-ns.ovn.kubernetes.io", MAC: "00:00:00:00:00:03", Source: u.NetInfoSourceAnnotations},
+	}
+
+	tests := []struct {
+		name   string
+		config backupConfig
+		want   []u.NetInfo
+	}{
+		{
+			name:   "fallback persists every identity",
+			config: backupConfig{persistedIdentity: persistedIdentityFallback},
+			want:   netInfos,
+		},
+		{
+			name:   "authoritative leaves the persisted identities out",
+			config: backupConfig{persistedIdentity: persistedIdentityAuthoritative},
+			want:   netInfos[:2],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.netInfosToPersist(netInfos); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("netInfosToPersist() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	v1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+// restoredInstance is a VM, or a VMI created without a VM, whose persisted network identity is being restored.
+// The identity is carried by the annotations of the template of a VM, or by the annotations of a VMI.
+type restoredInstance struct {
+	// kind is either VM or VMI, for humans
+	kind string
+	name string
+	// namespace is the namespace of the instance in the backup, see targetNamespace
+	namespace string
+	meta      *metav1.ObjectMeta
+	spec      *kvcore.VirtualMachineInstanceSpec
+}
+
+// restoredVM returns the restoredInstance of a VM with a template
+func restoredVM(vm *kvcore.VirtualMachine) *restoredInstance {
+	return &restoredInstance{
+		kind:      "VM",
+		name:      vm.Name,
+		namespace: vm.Namespace,
+		meta:      &vm.Spec.Template.ObjectMeta,
+		spec:      &vm.Spec.Template.Spec,
+	}
+}
+
+// restoredVMI returns the restoredInstance of a VMI
+func restoredVMI(vmi *kvcore.VirtualMachineInstance) *restoredInstance {
+	return &restoredInstance{
+		kind:      "VMI",
+		name:      vmi.Name,
+		namespace: vmi.Namespace,
+		meta:      &vmi.ObjectMeta,
+		spec:      &vmi.Spec,
+	}
+}
+
+// identityRestorer checks the persisted network identity of the VMs and VMIs being restored against the target
+// cluster, and rewrites it according to the settings of the restore
+type identityRestorer struct {
+	log logrus.FieldLogger
+}
+
+// restore translates the persisted identity of an instance into the target cluster, then drops it for clones, or
+// checks it and reserves its addresses otherwise
+func (r *identityRestorer) restore(instance *restoredInstance, restore *velerov1api.Restore, config *restoreConfig) error {
+	// CIDR mappings reference the networks as named in the backup, remember them before the namespace mapping
+	backupNetworkNames := multusNetworkNames(instance)
+
+	// Point the networks of the instance to the NADs of the namespaces it is restored into
+	if err := r.remapNamespaces(instance, restore.Spec.NamespaceMapping); err != nil {
+		return err
+	}
+
+	// Translate the persisted addresses into the address plan of the target cluster
+	namespace := targetNamespace(instance.namespace, restore.Spec.NamespaceMapping)
+	if err := r.remapCIDRs(instance, backupNetworkNames, namespace, config); err != nil {
+		return err
+	}
+
+	// A clone must get a fresh identity, there is nothing left to check
+	if config.mode == restoreModeClone {
+		r.dropIdentity(instance)
+		return nil
+	}
+
+	// Check every persisted identity against the target cluster, dropping what Kube-OVN would not be able to honor
+	annotations := instance.meta.Annotations
+	var macIndex u.MACIndex
+	subnets := make(map[string]string)
+	for _, netInfo := range u.NetInfosFromAnnotations(annotations) {
+		subnet, free, err := r.validateIPs(instance, namespace, &netInfo, annotations, config)
+		if err != nil {
+			return err
+		}
+		if subnet != nil && free {
+			subnets[netInfo.NADAnnotation] = subnet.Name
+		}
+		// Reserving an address kept despite a conflict would allocate it twice in the subnet
+		if subnet != nil && !free && config.reserveIPs {
+			r.netInfoLogger(instance, &netInfo).Warnf("Not reserving IP address %s: it is already used by another pod", netInfo.IPs)
+		}
+		if err := r.validateLogicalSwitch(instance, &netInfo, subnet, annotations); err != nil {
+			return err
+		}
+
+		// The identity may have been dropped entirely because of an IP conflict
+		if _, ok := annotations[netInfo.MACAnnotation()]; !ok {
+			continue
+		}
+
+		// The MAC index is only built once per instance, and only if it has a persisted MAC address
+		if macIndex == nil {
+			macIndex, err = u.BuildMACIndex()
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := r.validateMAC(instance, namespace, &netInfo, subnet, annotations, config, macIndex); err != nil {
+			return err
+		}
+	}
+
+	// Hold the addresses that survived the checks, so that no other workload grabs them before the instance starts
+	if config.reserveIPs {
+		if err := r.reserveIPs(instance, namespace, subnets, restore.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// targetNamespace returns the namespace an instance is restored into. Velero runs the restore item actions before
+// applying the namespace mapping of the restore, so the instance still carries the namespace it was backed up from.
+func targetNamespace(namespace string, namespaceMapping map[string]string) string {
+	if target, ok := namespaceMapping[namespace]; ok {
+		return target
+	}
+
+	return namespace
+}
+
+// dropIdentity removes the persisted network identity of an instance: the Kube-OVN annotations produced during the
+// backup, as well as the MAC addresses set on its interfaces. Kube-OVN then allocates new addresses to the instance.
+func (r *identityRestorer) dropIdentity(instance *restoredInstance) {
+	annotations := instance.meta.Annotations
+	for _, netInfo := range u.NetInfosFromAnnotations(annotations) {
+		r.netInfoLogger(instance, &netInfo).Infof("Dropping persisted identity of the clone")
+		netInfo.DeleteAnnotations(annotations)
+	}
+
+	for i, iface := range instance.spec.Domain.Devices.Interfaces {
+		if iface.MacAddress != "" {
+			r.log.Infof("Dropping MAC address %s of interface %s of the clone %s/%s", iface.MacAddress, iface.Name, instance.namespace, instance.name)
+			instance.spec.Domain.Devices.Interfaces[i].MacAddress = ""
+		}
+	}
+}
+
+// multusNetworkNames returns the Multus network names of the networks of an instance, empty for the other networks
+func multusNetworkNames(instance *restoredInstance) []string {
+	names := make([]string, len(instance.spec.Networks))
+	for i, network := range instance.spec.Networks {
+		if network.Multus != nil {
+			names[i] = network.Multus.NetworkName
+		}
+	}
+
+	return names
+}
+
+// remapCIDRs translates the persisted IP addresses of an instance according to the CIDR mappings of the restore.
+// A disaster recovery cluster may use other subnet CIDRs, in which the original addresses would be rejected.
+// The mappings restricted to a network match the Multus networks of the instance as named in the backup, while its
+// persisted identity is named after the providers of the NADs it is restored with, in the namespace it is restored into.
+func (r *identityRestorer) remapCIDRs(instance *restoredInstance, backupNetworkNames []string, namespace string, config *restoreConfig) error {
+	if len(config.cidrMappings) == 0 {
+		return nil
+	}
+
+	// Interfaces only get the mappings without a network, unless the NAD they are attached to is named by one
+	defaultMappings := config.cidrMappingsFor("", instance.namespace)
+	mappingsOfNads := make(map[string][]u.CIDRMapping)
+	for i, network := range instance.spec.Networks {
+		if network.Multus == nil {
+			continue
+		}
+
+		mappings := config.cidrMappingsFor(backupNetworkNames[i], instance.namespace)
+		if len(mappings) == len(defaultMappings) {
+			continue
+		}
+
+		// The default provider is only assumed when the NAD can't be found
+		nadAnnotation, err := u.ResolveNadAnnotation(network.Multus.NetworkName, namespace)
+		if errors.Is(err, u.ErrUnmanagedNetwork) || errors.Is(err, u.ErrIPAMOnlyNetwork) {
+			// No identity is persisted for the networks Kube-OVN doesn't manage
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resolve network %s of %s %s/%s: %w", network.Name, strings.ToLower(instance.kind), instance.namespace, instance.name, err)
+		}
+		mappingsOfNads[nadAnnotation] = mappings
+	}
+
+	annotations := instance.meta.Annotations
+	for _, netInfo := range u.NetInfosFromAnnotations(annotations) {
+		mappings, ok := mappingsOfNads[netInfo.NADAnnotation]
+		if !ok {
+			mappings = defaultMappings
+		}
+		if netInfo.IPs == "" || len(mappings) == 0 {
+			continue
+		}
+
+		// Malformed addresses are left untouched, they are dropped later on by the validation
+		ips, err := u.RemapIPs(netInfo.IPs, mappings)
+		if err != nil {
+			continue
+		}
+
+		if ips != netInfo.IPs {
+			r.netInfoLogger(instance, &netInfo).Infof("Remapping persisted IP address %s to %s", netInfo.IPs, ips)
+			annotations[netInfo.IPAnnotation()] = ips
+		}
+	}
+
+	return nil
+}
+
+// remapNamespaces rewrites the Multus network names and the NAD annotations of an instance according to the
+// namespace mapping of the restore. Without it, an instance restored into another namespace would reference NADs
+// that don't exist.
+func (r *identityRestorer) remapNamespaces(instance *restoredInstance, namespaceMapping map[string]string) error {
+	if len(namespaceMapping) == 0 {
+		return nil
+	}
+
+	for i, network := range instance.spec.Networks {
+		if network.Multus == nil {
+			continue
+		}
+
+		networkName, err := u.RemapNetworkName(network.Multus.NetworkName, namespaceMapping)
+		if err != nil {
+			return fmt.Errorf("invalid network name for %s %s/%s: %w", strings.ToLower(instance.kind), instance.namespace, instance.name, err)
+		}
+		if networkName != network.Multus.NetworkName {
+			r.log.Infof("Remapping network %s of %s %s/%s from %s to %s", network.Name, instance.kind, instance.namespace, instance.name, network.Multus.NetworkName, networkName)
+			instance.spec.Networks[i].Multus.NetworkName = networkName
+		}
+	}
+
+	if instance.meta.Annotations != nil {
+		annotations, err := u.RemapNadAnnotations(instance.meta.Annotations, namespaceMapping)
+		if err != nil {
+			return fmt.Errorf("failed to remap NAD annotations of %s %s/%s: %w", strings.ToLower(instance.kind), instance.namespace, instance.name, err)
+		}
+		instance.meta.Annotations = annotations
+	}
+
+	return nil
+}
+
+// validateIPs checks the persisted IP addresses of an interface and removes them from the annotations if they are
+// invalid in the target cluster. A malformed IP address, or one that doesn't belong to any Kube-OVN subnet, would
+// prevent the instance from getting a network interface, so we let Kube-OVN allocate a new one instead.
+// The instance is expected to be restored into the given namespace, which may differ from its namespace in the backup.
+// Returns the subnet the addresses belong to, or nil if it is unknown, and whether no other pod holds them.
+func (r *identityRestorer) validateIPs(instance *restoredInstance, namespace string, netInfo *u.NetInfo, annotations map[string]string, config *restoreConfig) (*v1.Subnet, bool, error) {
+	log := r.netInfoLogger(instance, netInfo)
+
+	if netInfo.IPs == "" {
+		return nil, false, nil
+	}
+
+	if err := netInfo.ValidateIPs(); err != nil {
+		log.Warnf("Dropping persisted IP address: %v", err)
+		delete(annotations, netInfo.IPAnnotation())
+		return nil, false, nil
+	}
+
+	subnet, err := u.GetSubnetForIPs(netInfo.IPs, netInfo.Subnet)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check the IP addresses of %s %s/%s: %w", instance.kind, instance.namespace, instance.name, err)
+	}
+	if subnet == nil {
+		log.Warnf("Dropping persisted IP address %s: no Kube-OVN subnet of the cluster contains it", netInfo.IPs)
+		delete(annotations, netInfo.IPAnnotation())
+		return nil, false, nil
+	}
+
+	// Another pod may still hold the address, for example the original instance when restoring next to it
+	conflicts, err := u.GetConflictingIPs(netInfo.IPs, subnet.Name, instance.name, namespace)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check the IP addresses of %s %s/%s: %w", instance.kind, instance.namespace, instance.name, err)
+	}
+	if len(conflicts) > 0 {
+		return subnet, false, r.handleIPConflict(log, netInfo, conflicts, annotations, config.ipConflictPolicy)
+	}
+
+	log.Infof("Restoring persisted IP address %s from subnet %s", netInfo.IPs, subnet.Name)
+	return subnet, true, nil
+}
+
+// validateLogicalSwitch checks the persisted logical switch of an interface against the subnet hosting its persisted
+// IP addresses, if known. Kube-OVN refuses to allocate an address outside of the logical switch of an interface, and
+// cannot attach an interface to a logical switch that doesn't exist.
+func (r *identityRestorer) validateLogicalSwitch(instance *restoredInstance, netInfo *u.NetInfo, subnet *v1.Subnet, annotations map[string]string) error {
+	if netInfo.Subnet == "" {
+		return nil
+	}
+	log := r.netInfoLogger(instance, netInfo)
+
+	// The addresses may have been translated into a subnet of another name, which they must be allocated from
+	if subnet != nil {
+		if subnet.Name != netInfo.Subnet {
+			log.Warnf("Moving persisted logical switch %s to subnet %s, which contains IP address %s", netInfo.Subnet, subnet.Name, netInfo.IPs)
+			annotations[netInfo.LogicalSwitchAnnotation()] = subnet.Name
+		}
+		return nil
+	}
+
+	existing, err := u.GetSubnet(netInfo.Subnet)
+	if err != nil {
+		return fmt.Errorf("failed to check the logical switch of %s %s/%s: %w", instance.kind, instance.namespace, instance.name, err)
+	}
+	if existing == nil {
+		log.Warnf("Dropping persisted logical switch %s: no Kube-OVN subnet of the cluster has this name", netInfo.Subnet)
+		delete(annotations, netInfo.LogicalSwitchAnnotation())
+	}
+
+	return nil
+}
+
+// validateMAC checks the persisted MAC address of an interface and removes it from the annotations if it is invalid
+// in the target cluster. Two interfaces sharing a MAC address on the same logical switch blackhole each other's traffic.
+// The instance is expected to be restored into the given namespace, which may differ from its namespace in the backup.
+func (r *identityRestorer) validateMAC(instance *restoredInstance, namespace string, netInfo *u.NetInfo, subnet *v1.Subnet, annotations map[string]string, config *restoreConfig, macIndex u.MACIndex) error {
+	log := r.netInfoLogger(instance, netInfo)
+
+	if err := netInfo.ValidateMAC(); err != nil {
+		log.Warnf("Dropping persisted MAC address: %v", err)
+		delete(annotations, netInfo.MACAnnotation())
+		return nil
+	}
+
+	conflicts := macIndex.Conflicts(netInfo.MAC, subnet, instance.name, namespace)
+	if len(conflicts) == 0 {
+		log.Infof("Restoring persisted MAC address %s", netInfo.MAC)
+		return nil
+	}
+
+	var owners []string
+	for _, owner := range conflicts {
+		owners = append(owners, fmt.Sprintf("%s/%s", owner.Namespace, owner.Name))
+	}
+
+	switch config.macConflictPolicy {
+	case conflictPolicyStrip:
+		log.Warnf("Dropping persisted MAC address %s to let Kube-OVN generate a new one: it is already used by %s", netInfo.MAC, strings.Join(owners, ", "))
+		delete(annotations, netInfo.MACAnnotation())
+		return nil
+	case conflictPolicyKeep:
+		log.Warnf("Keeping persisted MAC address %s although it is already used by %s", netInfo.MAC, strings.Join(owners, ", "))
+		return nil
+	default:
+		return fmt.Errorf("MAC address %s of %s is already used by %s", netInfo.MAC, netInfo.NADAnnotation, strings.Join(owners, ", "))
+	}
+}
+
+// reserveIPs reserves in Kube-OVN the persisted IP addresses of every interface of an instance whose subnet is known.
+// The reservations are made for the namespace the instance is restored into, so that Kube-OVN adopts them when it
+// starts. If an address cannot be reserved, the reservations made so far for the instance are released, so that they
+// don't hold addresses on behalf of an instance that won't be restored.
+func (r *identityRestorer) reserveIPs(instance *restoredInstance, namespace string, subnets map[string]string, restoreName string) error {
+	annotations := instance.meta.Annotations
+	labels := map[string]string{reservedByRestoreLabel: restoreName}
+
+	var reserved []u.NetInfo
+	for _, netInfo := range u.NetInfosFromAnnotations(annotations) {
+		subnet, ok := subnets[netInfo.NADAnnotation]
+		if !ok || netInfo.IPs == "" {
+			continue
+		}
+
+		created, err := u.ReserveIPForVM(&netInfo, subnet, instance.name, namespace, labels)
+		if err != nil {
+			r.releaseIPs(instance, namespace, reserved)
+			return err
+		}
+
+		log := r.netInfoLogger(instance, &netInfo)
+		if created {
+			reserved = append(reserved, netInfo)
+			log.Infof("Reserved IP address %s in subnet %s", netInfo.IPs, subnet)
+		} else {
+			log.Infof("IP address %s is already allocated to the %s, no reservation needed", netInfo.IPs, instance.kind)
+		}
+	}
+
+	return nil
+}
+
+// releaseIPs releases the reservations made for the interfaces of an instance. Failures are only logged, the IPs can
+// still be found and deleted by their reservedByRestoreLabel.
+func (r *identityRestorer) releaseIPs(instance *restoredInstance, namespace string, reserved []u.NetInfo) {
+	for _, netInfo := range reserved {
+		log := r.netInfoLogger(instance, &netInfo)
+		if err := u.ReleaseIPForVM(netInfo.NADAnnotation, instance.name, namespace); err != nil {
+			log.Errorf("Failed to release the reservation of IP address %s: %v", netInfo.IPs, err)
+			continue
+		}
+		log.Infof("Released the reservation of IP address %s", netInfo.IPs)
+	}
+}
+
+// netInfoLogger returns a logger reporting which interface of which instance a message is about
+func (r *identityRestorer) netInfoLogger(instance *restoredInstance, netInfo *u.NetInfo) logrus.FieldLogger {
+	return r.log.WithFields(logrus.Fields{
+		strings.ToLower(instance.kind): fmt.Sprintf("%s/%s", instance.namespace, instance.name),
+		"network":                      netInfo.NADAnnotation,
+	})
+}
+
+// handleIPConflict applies the conflict policy of the restore to a persisted IP address already used in the cluster
+func (r *identityRestorer) handleIPConflict(log logrus.FieldLogger, netInfo *u.NetInfo, conflicts []v1.IP, annotations map[string]string, policy conflictPolicy) error {
+	var owners []string
+	for _, ip := range conflicts {
+		owners = append(owners, fmt.Sprintf("%s/%s", ip.Spec.Namespace, ip.Spec.PodName))
+	}
+
+	switch policy {
+	case conflictPolicyStrip:
+		log.Warnf("Dropping persisted identity: IP address %s is already used by %s", netInfo.IPs, strings.Join(owners, ", "))
+		delete(annotations, netInfo.IPAnnotation())
+		delete(annotations, netInfo.MACAnnotation())
+		return nil
+	case conflictPolicyKeep:
+		log.Warnf("Keeping persisted IP address %s although it is already used by %s", netInfo.IPs, strings.Join(owners, ", "))
+		return nil
+	default:
+		return fmt.Errorf("IP address %s of %s is already used by %s", netInfo.IPs, netInfo.NADAnnotation, strings.Join(owners, ", "))
+	}
+}
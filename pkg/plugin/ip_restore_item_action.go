@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"fmt"
+
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// IPRestoreItemAction handles the Kube-OVN IP custom resources a backup may have captured. Restored verbatim, they
+// point to pods and nodes of the source cluster, which prevents Kube-OVN from allocating the addresses again.
+type IPRestoreItemAction struct {
+	log logrus.FieldLogger
+}
+
+func NewIPRestoreItemAction(logger logrus.FieldLogger) *IPRestoreItemAction {
+	return &IPRestoreItemAction{
+		log: logger,
+	}
+}
+
+func (r *IPRestoreItemAction) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: []string{"ips.kubeovn.io"},
+	}, nil
+}
+
+func (r *IPRestoreItemAction) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	r.log.Info("Executing IPRestoreItemAction")
+
+	// No restore, errors out
+	if input == nil || input.Restore == nil {
+		return nil, fmt.Errorf("restore object is nil")
+	}
+
+	config, err := newRestoreConfig(input.Restore)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ip := new(kubeovnv1.IP)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(input.Item.UnstructuredContent(), ip); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if reason := r.skipReason(ip, config, input.Restore.Spec.NamespaceMapping); reason != "" {
+		r.log.Infof("Skipping restore of IP %s: %s", ip.Name, reason)
+		return velero.NewRestoreItemActionExecuteOutput(input.Item).WithoutRestore(), nil
+	}
+
+	// Kube-OVN fills those fields again once the pod of the VM is scheduled, and adopts the IP as is until then
+	ip.Spec.NodeName = ""
+	ip.Spec.ContainerID = ""
+	if ip.Labels == nil {
+		ip.Labels = make(map[string]string)
+	}
+	ip.Labels[reservedByRestoreLabel] = input.Restore.Name
+	r.log.Infof("Restoring IP %s of VM %s/%s without its stale node and container", ip.Name, ip.Spec.Namespace, ip.Spec.PodName)
+
+	ipUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ip)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return velero.NewRestoreItemActionExecuteOutput(&unstructured.Unstructured{Object: ipUnstructured}), nil
+}
+
+// skipReason explains why an IP must not be restored, or returns an empty string if it can be rewritten
+func (r *IPRestoreItemAction) skipReason(ip *kubeovnv1.IP, config *restoreConfig, namespaceMapping map[string]string) string {
+	switch {
+	case config.ipRestorePolicy == ipRestorePolicySkip:
+		return "the identity is carried by the VM annotations"
+	case config.mode == restoreModeClone:
+		return "clones get a fresh identity"
+	case ip.Spec.PodType != u.VirtualMachinePodType:
+		// The name of other pods changes when they are recreated, the IP would never be adopted
+		return "it doesn't belong to a VM"
+	case namespaceMapping[ip.Spec.Namespace] != "":
+		return fmt.Sprintf("namespace %s is mapped to %s, the IP would not match the restored VM", ip.Spec.Namespace, namespaceMapping[ip.Spec.Namespace])
+	case len(config.cidrMappings) > 0:
+		return "the addresses of the VM annotations are translated into other CIDRs"
+	default:
+		return ""
+	}
+}
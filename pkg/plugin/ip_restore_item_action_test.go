@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"testing"
+
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/sirupsen/logrus"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	riav1 "github.com/vmware-tanzu/velero/pkg/plugin/velero/restoreitemaction/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The IP restore action is registered as a v1 restore action
+var _ riav1.RestoreItemAction = &IPRestoreItemAction{}
+
+func TestIPRestoreExecute(t *testing.T) {
+	logger := logrus.New()
+	action := NewIPRestoreItemAction(logger)
+
+	vmIP := kubeovnv1.IP{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-vm.test-ns",
+		},
+		Spec: kubeovnv1.IPSpec{
+			PodName:     "test-vm",
+			Namespace:   "test-ns",
+			Subnet:      "ovn-default",
+			NodeName:    "source-node",
+			ContainerID: "0123456789abcdef",
+			V4IPAddress: "10.0.0.1",
+			IPAddress:   "10.0.0.1",
+			MacAddress:  "00:00:00:00:00:01",
+			PodType:     "VirtualMachine",
+		},
+	}
+	podIP := *vmIP.DeepCopy()
+	podIP.Name = "test-pod-abcde.test-ns"
+	podIP.Spec.PodName = "test-pod-abcde"
+	podIP.Spec.PodType = ""
+
+	tests := []struct {
+		name           string
+		ip             kubeovnv1.IP
+		restore        *velerov1api.Restore
+		wantSkip       bool
+		wantRestoredBy string
+		wantErr        bool
+	}{
+		{
+			name: "Skipped by default",
+			ip:   vmIP,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-restore"},
+			},
+			wantSkip: true,
+			wantErr:  false,
+		},
+		{
+			name: "IP of a VM rewritten",
+			ip:   vmIP,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-restore",
+					Annotations: map[string]string{ipRestorePolicyAnnotation: "rewrite"},
+				},
+			},
+			wantSkip:       false,
+			wantRestoredBy: "test-restore",
+			wantErr:        false,
+		},
+		{
+			name: "IP of a pod skipped when rewriting",
+			ip:   podIP,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-restore",
+					Annotations: map[string]string{ipRestorePolicyAnnotation: "rewrite"},
+				},
+			},
+			wantSkip: true,
+			wantErr:  false,
+		},
+		{
+			name: "IP skipped when cloning",
+			ip:   vmIP,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-restore",
+					Annotations: map[string]string{
+						ipRestorePolicyAnnotation: "rewrite",
+						restoreModeKey:            "clone",
+					},
+				},
+			},
+			wantSkip: true,
+			wantErr:  false,
+		},
+		{
+			name: "IP of a mapped namespace skipped when rewriting",
+			ip:   vmIP,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-restore",
+					Annotations: map[string]string{ipRestorePolicyAnnotation: "rewrite"},
+				},
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "other-ns"},
+				},
+			},
+			wantSkip: true,
+			wantErr:  false,
+		},
+		{
+			name: "IP skipped when translating CIDRs",
+			ip:   vmIP,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-restore",
+					Annotations: map[string]string{
+						ipRestorePolicyAnnotation: "rewrite",
+						cidrMappingAnnotation:     `[{"from": "10.0.0.0/24", "to": "10.1.0.0/24"}]`,
+					},
+				},
+			},
+			wantSkip: true,
+			wantErr:  false,
+		},
+		{
+			name: "Invalid IP restore policy",
+			ip:   vmIP,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ipRestorePolicyAnnotation: "verbatim"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Nil restore object",
+			ip:      vmIP,
+			restore: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&tt.ip)
+			if err != nil {
+				t.Fatalf("failed to convert IP to unstructured: %v", err)
+			}
+
+			input := &velero.RestoreItemActionExecuteInput{
+				Item:    &unstructured.Unstructured{Object: ipUnstructured},
+				Restore: tt.restore,
+			}
+
+			got, err := action.Execute(input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.SkipRestore != tt.wantSkip {
+				t.Errorf("Execute() skipRestore = %v, want %v", got.SkipRestore, tt.wantSkip)
+			}
+			if tt.wantSkip {
+				return
+			}
+
+			gotIP := new(kubeovnv1.IP)
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(got.UpdatedItem.UnstructuredContent(), gotIP); err != nil {
+				t.Fatalf("failed to convert returned item back to IP: %v", err)
+			}
+
+			if gotIP.Spec.NodeName != "" || gotIP.Spec.ContainerID != "" {
+				t.Errorf("Execute() kept stale node %q and container %q", gotIP.Spec.NodeName, gotIP.Spec.ContainerID)
+			}
+			if gotIP.Spec.PodName != tt.ip.Spec.PodName || gotIP.Spec.V4IPAddress != tt.ip.Spec.V4IPAddress || gotIP.Spec.MacAddress != tt.ip.Spec.MacAddress {
+				t.Errorf("Execute() got spec %+v, want the identity of %+v", gotIP.Spec, tt.ip.Spec)
+			}
+			if gotIP.Labels[reservedByRestoreLabel] != tt.wantRestoredBy {
+				t.Errorf("Execute() got label %s = %q, want %q", reservedByRestoreLabel, gotIP.Labels[reservedByRestoreLabel], tt.wantRestoredBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// LauncherPodRestoreItemAction removes from the restored virt-launcher pods the addresses Kube-OVN allocated to them
+// in the source cluster. Kube-OVN would otherwise trust them over the identity persisted in the template of the VM.
+type LauncherPodRestoreItemAction struct {
+	log logrus.FieldLogger
+}
+
+func NewLauncherPodRestoreItemAction(logger logrus.FieldLogger) *LauncherPodRestoreItemAction {
+	return &LauncherPodRestoreItemAction{
+		log: logger,
+	}
+}
+
+func (p *LauncherPodRestoreItemAction) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: []string{"pods"},
+		LabelSelector:     u.LauncherPodSelector,
+	}, nil
+}
+
+func (p *LauncherPodRestoreItemAction) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	p.log.Info("Executing LauncherPodRestoreItemAction")
+
+	// No restore, errors out
+	if input == nil || input.Restore == nil {
+		return nil, fmt.Errorf("restore object is nil")
+	}
+
+	pod := new(corev1.Pod)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(input.Item.UnstructuredContent(), pod); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, key := range u.StripAllocationAnnotations(pod.Annotations) {
+		p.log.Infof("Dropping stale annotation %s of virt-launcher pod %s/%s", key, pod.Namespace, pod.Name)
+	}
+
+	podUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return velero.NewRestoreItemActionExecuteOutput(&unstructured.Unstructured{Object: podUnstructured}), nil
+}
@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	riav1 "github.com/vmware-tanzu/velero/pkg/plugin/velero/restoreitemaction/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The launcher pod restore action is registered as a v1 restore action
+var _ riav1.RestoreItemAction = &LauncherPodRestoreItemAction{}
+
+func TestLauncherPodRestoreExecute(t *testing.T) {
+	logger := logrus.New()
+	action := NewLauncherPodRestoreItemAction(logger)
+
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		restore         *velerov1api.Restore
+		wantAnnotations map[string]string
+		wantErr         bool
+	}{
+		{
+			name: "Stale allocation annotations are dropped",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/allocated":                       "true",
+				"ovn.kubernetes.io/routed":                          "true",
+				"ovn.kubernetes.io/ip_address":                      "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                     "00:00:00:00:00:01",
+				"ovn.kubernetes.io/cidr":                            "10.0.0.0/24",
+				"ovn.kubernetes.io/gateway":                         "10.0.0.254",
+				"ovn.kubernetes.io/logical_switch":                  "ovn-default",
+				"ovn.kubernetes.io/pod_nic_type":                    "veth-pair",
+				"test-nad.test-ns.ovn.kubernetes.io/allocated":      "true",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address":     "10.0.1.1",
+				"test-nad.test-ns.ovn.kubernetes.io/logical_router": "ovn-cluster",
+				"kubevirt.io/domain":                                "test-vm",
+				"ovn.kubernetes.io/security_groups":                 "sg1",
+			},
+			restore: &velerov1api.Restore{},
+			wantAnnotations: map[string]string{
+				"kubevirt.io/domain":                "test-vm",
+				"ovn.kubernetes.io/security_groups": "sg1",
+			},
+			wantErr: false,
+		},
+		{
+			name:            "Pod without annotations",
+			annotations:     nil,
+			restore:         &velerov1api.Restore{},
+			wantAnnotations: map[string]string{},
+			wantErr:         false,
+		},
+		{
+			name:    "Nil restore object",
+			restore: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "virt-launcher-test-vm-abcde",
+					Namespace:   "test-ns",
+					Labels:      map[string]string{"kubevirt.io": "virt-launcher"},
+					Annotations: tt.annotations,
+				},
+			}
+
+			podUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+			if err != nil {
+				t.Fatalf("failed to convert pod to unstructured: %v", err)
+			}
+
+			input := &velero.RestoreItemActionExecuteInput{
+				Item:    &unstructured.Unstructured{Object: podUnstructured},
+				Restore: tt.restore,
+			}
+
+			got, err := action.Execute(input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			gotPod := new(corev1.Pod)
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(got.UpdatedItem.UnstructuredContent(), gotPod); err != nil {
+				t.Fatalf("failed to convert returned item back to pod: %v", err)
+			}
+
+			if len(gotPod.Annotations) != len(tt.wantAnnotations) {
+				t.Errorf("Execute() got annotations %v, want %v", gotPod.Annotations, tt.wantAnnotations)
+			}
+			for k, v := range tt.wantAnnotations {
+				if gotPod.Annotations[k] != v {
+					t.Errorf("Execute() annotation %s = %v, want %v", k, gotPod.Annotations[k], v)
+				}
+			}
+		})
+	}
+}
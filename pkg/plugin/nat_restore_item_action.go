@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// natInternalAddressFields lists, for every kind of FIP and DNAT rule, the spec fields holding the address of the VM
+var natInternalAddressFields = map[string][]string{
+	"OvnFip":           {"v4Ip", "v6Ip"},
+	"OvnDnatRule":      {"v4Ip", "v6Ip"},
+	"IptablesFIPRule":  {"internalIp"},
+	"IptablesDnatRule": {"internalIp"},
+}
+
+// NATRestoreItemAction recreates the FIP and DNAT rules bound to the addresses of the VMs, following the VMs when
+// their addresses are translated into other CIDRs or their namespace is mapped
+type NATRestoreItemAction struct {
+	log logrus.FieldLogger
+}
+
+func NewNATRestoreItemAction(logger logrus.FieldLogger) *NATRestoreItemAction {
+	return &NATRestoreItemAction{
+		log: logger,
+	}
+}
+
+func (n *NATRestoreItemAction) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: []string{
+			"ovn-fips.kubeovn.io",
+			"ovn-dnat-rules.kubeovn.io",
+			"iptables-fip-rules.kubeovn.io",
+			"iptables-dnat-rules.kubeovn.io",
+		},
+	}, nil
+}
+
+func (n *NATRestoreItemAction) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	n.log.Info("Executing NATRestoreItemAction")
+
+	// No restore, errors out
+	if input == nil || input.Restore == nil {
+		return nil, fmt.Errorf("restore object is nil")
+	}
+
+	config, err := newRestoreConfig(input.Restore)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	item := &unstructured.Unstructured{Object: input.Item.UnstructuredContent()}
+	kind, name := item.GetKind(), item.GetName()
+
+	// Clones get a fresh identity, the public addresses stay bound to the originals
+	if config.mode == restoreModeClone {
+		n.log.Infof("Skipping restore of %s %s: clones get a fresh identity", kind, name)
+		return velero.NewRestoreItemActionExecuteOutput(input.Item).WithoutRestore(), nil
+	}
+
+	// The network of the VM isn't known here, so every mapping applies, in the order they are declared
+	var mappings []u.CIDRMapping
+	for _, mapping := range config.cidrMappings {
+		mappings = append(mappings, mapping.mapping)
+	}
+
+	for _, field := range natInternalAddressFields[kind] {
+		address, found, err := unstructured.NestedString(item.Object, "spec", field)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !found || address == "" {
+			continue
+		}
+
+		remapped, err := u.RemapIPs(address, mappings)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address of %s %s: %w", kind, name, err)
+		}
+		if remapped != address {
+			n.log.Infof("Translating address %s of %s %s into %s", address, kind, name, remapped)
+			if err := unstructured.SetNestedField(item.Object, remapped, "spec", field); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
+	// OVN rules may reference the IP of the VM by name, which embeds its namespace
+	ipName, found, err := unstructured.NestedString(item.Object, "spec", "ipName")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if found && ipName != "" {
+		remapped, err := u.RemapIPName(ipName, input.Restore.Spec.NamespaceMapping)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remap IP name of %s %s: %w", kind, name, err)
+		}
+		if remapped != ipName {
+			n.log.Infof("Rewriting IP name %s of %s %s into %s", ipName, kind, name, remapped)
+			if err := unstructured.SetNestedField(item.Object, remapped, "spec", "ipName"); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
+	return velero.NewRestoreItemActionExecuteOutput(item), nil
+}
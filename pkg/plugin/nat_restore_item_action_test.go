@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"testing"
+
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/sirupsen/logrus"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	riav1 "github.com/vmware-tanzu/velero/pkg/plugin/velero/restoreitemaction/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The NAT restore action is registered as a v1 restore action
+var _ riav1.RestoreItemAction = &NATRestoreItemAction{}
+
+func TestNATRestoreExecute(t *testing.T) {
+	logger := logrus.New()
+	action := NewNATRestoreItemAction(logger)
+
+	ovnFip := &kubeovnv1.OvnFip{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kubeovn.io/v1", Kind: "OvnFip"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-fip"},
+		Spec: kubeovnv1.OvnFipSpec{
+			OvnEip: "test-eip",
+			IPName: "test-vm.test-ns",
+			V4Ip:   "10.16.0.5",
+		},
+	}
+	iptablesDnat := &kubeovnv1.IptablesDnatRule{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kubeovn.io/v1", Kind: "IptablesDnatRule"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-dnat"},
+		Spec: kubeovnv1.IptablesDnatRuleSpec{
+			EIP:          "test-eip",
+			ExternalPort: "2222",
+			Protocol:     "tcp",
+			InternalIP:   "10.16.0.5",
+			InternalPort: "22",
+		},
+	}
+	mappingAnnotations := map[string]string{
+		cidrMappingAnnotation: `[{"from": "10.16.0.0/16", "to": "10.116.0.0/16"}]`,
+	}
+
+	tests := []struct {
+		name       string
+		item       runtime.Object
+		restore    *velerov1api.Restore
+		wantSkip   bool
+		wantFields map[string]string
+		wantErr    bool
+	}{
+		{
+			name: "OVN FIP restored as is",
+			item: ovnFip,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-restore"},
+			},
+			wantFields: map[string]string{"v4Ip": "10.16.0.5", "ipName": "test-vm.test-ns", "ovnEip": "test-eip"},
+			wantErr:    false,
+		},
+		{
+			name: "OVN FIP following a translated and mapped VM",
+			item: ovnFip,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-restore", Annotations: mappingAnnotations},
+				Spec:       velerov1api.RestoreSpec{NamespaceMapping: map[string]string{"test-ns": "other-ns"}},
+			},
+			wantFields: map[string]string{"v4Ip": "10.116.0.5", "ipName": "test-vm.other-ns", "ovnEip": "test-eip"},
+			wantErr:    false,
+		},
+		{
+			name: "iptables DNAT rule following a translated VM",
+			item: iptablesDnat,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-restore", Annotations: mappingAnnotations},
+			},
+			wantFields: map[string]string{"internalIp": "10.116.0.5", "eip": "test-eip", "internalPort": "22"},
+			wantErr:    false,
+		},
+		{
+			name: "Skipped when cloning",
+			item: iptablesDnat,
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-restore", Annotations: map[string]string{restoreModeKey: "clone"}},
+			},
+			wantSkip: true,
+			wantErr:  false,
+		},
+		{
+			name:    "Nil restore object",
+			item:    ovnFip,
+			restore: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			itemUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(tt.item)
+			if err != nil {
+				t.Fatalf("failed to convert item to unstructured: %v", err)
+			}
+
+			input := &velero.RestoreItemActionExecuteInput{
+				Item:    &unstructured.Unstructured{Object: itemUnstructured},
+				Restore: tt.restore,
+			}
+
+			got, err := action.Execute(input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.SkipRestore != tt.wantSkip {
+				t.Errorf("Execute() skipRestore = %v, want %v", got.SkipRestore, tt.wantSkip)
+			}
+			if tt.wantSkip {
+				return
+			}
+
+			for field, want := range tt.wantFields {
+				value, _, _ := unstructured.NestedString(got.UpdatedItem.UnstructuredContent(), "spec", field)
+				if value != want {
+					t.Errorf("Execute() spec.%s = %q, want %q", field, value, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+const (
+	// ipConflictPolicyAnnotation selects what happens when a persisted IP address is already used in the target cluster
+	ipConflictPolicyAnnotation = "superphenix.net/ip-conflict-policy"
+	// macConflictPolicyAnnotation selects what happens when a persisted MAC address is already used on the same logical switch
+	macConflictPolicyAnnotation = "superphenix.net/mac-conflict-policy"
+	// restoreModeKey selects the restore mode, it can be set either as a label or as an annotation
+	restoreModeKey = "superphenix.net/restore-mode"
+	// cidrMappingAnnotation holds the translations to apply to the persisted IP addresses, as a JSON list of cidrMappingSpec
+	cidrMappingAnnotation = "superphenix.net/cidr-mapping"
+	// reserveIPsAnnotation enables the reservation in Kube-OVN of the persisted IP addresses before the VMs start
+	reserveIPsAnnotation = "superphenix.net/reserve-ips"
+	// waitForNetworkAnnotation enables an asynchronous operation waiting for the VMs to come up with their persisted identity
+	waitForNetworkAnnotation = "superphenix.net/wait-for-network"
+	// networkWaitTimeoutAnnotation overrides how long to wait for the VMs to come up with their persisted identity
+	networkWaitTimeoutAnnotation = "superphenix.net/wait-for-network-timeout"
+	// verifyNetworkAnnotation enables the verification report of the network identity of the restored VMs
+	verifyNetworkAnnotation = "superphenix.net/verify-network"
+	// verificationConfigMapAnnotation names a ConfigMap of the Velero namespace to store the verification reports into
+	verificationConfigMapAnnotation = "superphenix.net/verification-configmap"
+	// ipRestorePolicyAnnotation selects what happens to the Kube-OVN IP custom resources captured by a backup
+	ipRestorePolicyAnnotation = "superphenix.net/ip-restore-policy"
+
+	// defaultNetworkWaitTimeout is how long to wait for the VMs to come up with their persisted identity by default
+	defaultNetworkWaitTimeout = 10 * time.Minute
+
+	// reservedByRestoreLabel is set on the Kube-OVN IPs reserved by a restore, with the name of the restore as value
+	reservedByRestoreLabel = "superphenix.net/reserved-by-restore"
+)
+
+// restoreMode describes what the restore does with the persisted network identity of the VMs
+type restoreMode string
+
+const (
+	// restoreModeRestore restores the persisted network identity
+	restoreModeRestore restoreMode = "restore"
+	// restoreModeClone drops the persisted network identity, so that the restored VMs don't conflict with the originals
+	restoreModeClone restoreMode = "clone"
+)
+
+// ipRestorePolicy describes what the restore does with the Kube-OVN IP custom resources captured by a backup
+type ipRestorePolicy string
+
+const (
+	// ipRestorePolicySkip doesn't restore the IPs, the identity persisted in the VM annotations is enough
+	ipRestorePolicySkip ipRestorePolicy = "skip"
+	// ipRestorePolicyRewrite restores the IPs of the VMs, without the fields pointing to the pods of the source cluster
+	ipRestorePolicyRewrite ipRestorePolicy = "rewrite"
+)
+
+// conflictPolicy describes how the restore reacts to a persisted identity that is already used in the target cluster
+type conflictPolicy string
+
+const (
+	// conflictPolicyFail fails the restore of the item
+	conflictPolicyFail conflictPolicy = "fail"
+	// conflictPolicyStrip removes the persisted identity, letting Kube-OVN allocate a new one
+	conflictPolicyStrip conflictPolicy = "strip"
+	// conflictPolicyKeep keeps the persisted identity and only logs a warning
+	conflictPolicyKeep conflictPolicy = "keep"
+)
+
+// restoreConfig holds the settings of a restore. They are read from the annotations of the Velero Restore,
+// so that every restore can be tuned independently.
+type restoreConfig struct {
+	mode               restoreMode
+	ipConflictPolicy   conflictPolicy
+	macConflictPolicy  conflictPolicy
+	cidrMappings       []cidrMapping
+	reserveIPs         bool
+	waitForNetwork     bool
+	networkWaitTimeout time.Duration
+	verifyNetwork      bool
+	// verificationConfigMap is the ConfigMap storing the verification reports, they are only logged if empty
+	verificationConfigMap string
+	ipRestorePolicy       ipRestorePolicy
+}
+
+// cidrMappingSpec is the user-facing definition of a CIDR mapping
+type cidrMappingSpec struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Network restricts the mapping to the interfaces attached to a Multus network, as a [NS]/[NAD] or [NAD] network
+	// name matching the one of the instances in the backup. When empty, the mapping applies to every interface.
+	Network string `json:"network,omitempty"`
+}
+
+// cidrMapping is a CIDR mapping restricted to the interfaces attached to a Multus network, or to every interface if empty
+type cidrMapping struct {
+	network string
+	mapping u.CIDRMapping
+}
+
+// newRestoreConfig reads the settings of a restore from its annotations, falling back to the defaults
+func newRestoreConfig(restore *velerov1api.Restore) (*restoreConfig, error) {
+	config := &restoreConfig{
+		mode:               restoreModeRestore,
+		ipConflictPolicy:   conflictPolicyFail,
+		macConflictPolicy:  conflictPolicyFail,
+		networkWaitTimeout: defaultNetworkWaitTimeout,
+		ipRestorePolicy:    ipRestorePolicySkip,
+	}
+
+	// The annotation takes precedence over the label, as it isn't limited by the syntax of label values
+	value, ok := restore.Annotations[restoreModeKey]
+	if !ok {
+		value, ok = restore.Labels[restoreModeKey]
+	}
+	if ok {
+		mode, err := parseRestoreMode(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid restore mode %s: %w", restoreModeKey, err)
+		}
+		config.mode = mode
+	}
+
+	if value, ok := restore.Annotations[ipConflictPolicyAnnotation]; ok {
+		policy, err := parseConflictPolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", ipConflictPolicyAnnotation, err)
+		}
+		config.ipConflictPolicy = policy
+	}
+
+	if value, ok := restore.Annotations[macConflictPolicyAnnotation]; ok {
+		policy, err := parseConflictPolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", macConflictPolicyAnnotation, err)
+		}
+		config.macConflictPolicy = policy
+	}
+
+	if value, ok := restore.Annotations[cidrMappingAnnotation]; ok {
+		mappings, err := parseCIDRMappings(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", cidrMappingAnnotation, err)
+		}
+		config.cidrMappings = mappings
+	}
+
+	if value, ok := restore.Annotations[reserveIPsAnnotation]; ok {
+		reserve, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", reserveIPsAnnotation, err)
+		}
+		config.reserveIPs = reserve
+	}
+
+	if value, ok := restore.Annotations[waitForNetworkAnnotation]; ok {
+		wait, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", waitForNetworkAnnotation, err)
+		}
+		config.waitForNetwork = wait
+	}
+
+	if value, ok := restore.Annotations[networkWaitTimeoutAnnotation]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", networkWaitTimeoutAnnotation, err)
+		}
+		if timeout <= 0 {
+			return nil, fmt.Errorf("invalid annotation %s: expected a positive duration, got %s", networkWaitTimeoutAnnotation, value)
+		}
+		config.networkWaitTimeout = timeout
+	}
+
+	if value, ok := restore.Annotations[verifyNetworkAnnotation]; ok {
+		verify, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", verifyNetworkAnnotation, err)
+		}
+		config.verifyNetwork = verify
+	}
+
+	// Asking for the reports to be stored implies asking for them
+	if value, ok := restore.Annotations[verificationConfigMapAnnotation]; ok && value != "" {
+		config.verifyNetwork = true
+		config.verificationConfigMap = value
+	}
+
+	if value, ok := restore.Annotations[ipRestorePolicyAnnotation]; ok {
+		policy, err := parseIPRestorePolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", ipRestorePolicyAnnotation, err)
+		}
+		config.ipRestorePolicy = policy
+	}
+
+	return config, nil
+}
+
+// cidrMappingsFor returns the CIDR mappings that apply to the interfaces attached to a Multus network, as named in the
+// backup by an instance of a namespace. An empty network name only gets the mappings that apply to every interface.
+func (c *restoreConfig) cidrMappingsFor(networkName, namespace string) []u.CIDRMapping {
+	var mappings []u.CIDRMapping
+	for _, mapping := range c.cidrMappings {
+		if mapping.network == "" || (networkName != "" && u.SameNetwork(mapping.network, networkName, namespace)) {
+			mappings = append(mappings, mapping.mapping)
+		}
+	}
+
+	return mappings
+}
+
+// parseCIDRMappings translates the JSON value of an annotation into CIDR mappings
+func parseCIDRMappings(value string) ([]cidrMapping, error) {
+	var specs []cidrMappingSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, fmt.Errorf("expected a JSON list of CIDR mappings: %w", err)
+	}
+
+	var mappings []cidrMapping
+	for _, spec := range specs {
+		mapping, err := u.NewCIDRMapping(spec.From, spec.To)
+		if err != nil {
+			return nil, err
+		}
+
+		if spec.Network != "" {
+			if err := u.ValidateNetworkName(spec.Network); err != nil {
+				return nil, err
+			}
+		}
+
+		mappings = append(mappings, cidrMapping{network: spec.Network, mapping: *mapping})
+	}
+
+	return mappings, nil
+}
+
+// parseConflictPolicy translates the value of an annotation into a conflictPolicy
+func parseConflictPolicy(value string) (conflictPolicy, error) {
+	switch policy := conflictPolicy(value); policy {
+	case conflictPolicyFail, conflictPolicyStrip, conflictPolicyKeep:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("expected one of %s, %s or %s, got %s", conflictPolicyFail, conflictPolicyStrip, conflictPolicyKeep, value)
+	}
+}
+
+// parseRestoreMode translates the value of a label or annotation into a restoreMode
+func parseRestoreMode(value string) (restoreMode, error) {
+	switch mode := restoreMode(value); mode {
+	case restoreModeRestore, restoreModeClone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("expected one of %s or %s, got %s", restoreModeRestore, restoreModeClone, value)
+	}
+}
+
+// parseIPRestorePolicy translates the value of an annotation into an ipRestorePolicy
+func parseIPRestorePolicy(value string) (ipRestorePolicy, error) {
+	switch policy := ipRestorePolicy(value); policy {
+	case ipRestorePolicySkip, ipRestorePolicyRewrite:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("expected one of %s or %s, got %s", ipRestorePolicySkip, ipRestorePolicyRewrite, value)
+	}
+}
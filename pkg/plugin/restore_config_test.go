@@ -0,0 +1,309 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewRestoreConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        restoreConfig
+		wantErr     bool
+	}{
+		{
+			name:        "defaults",
+			annotations: nil,
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyFail,
+				macConflictPolicy:  conflictPolicyFail,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "strip IP conflicts",
+			annotations: map[string]string{
+				ipConflictPolicyAnnotation: "strip",
+			},
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyStrip,
+				macConflictPolicy:  conflictPolicyFail,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "keep IP conflicts",
+			annotations: map[string]string{
+				ipConflictPolicyAnnotation: "keep",
+			},
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyKeep,
+				macConflictPolicy:  conflictPolicyFail,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "strip MAC conflicts",
+			annotations: map[string]string{
+				macConflictPolicyAnnotation: "strip",
+			},
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyFail,
+				macConflictPolicy:  conflictPolicyStrip,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid MAC conflict policy",
+			annotations: map[string]string{
+				macConflictPolicyAnnotation: "regenerate",
+			},
+			wantErr: true,
+		},
+		{
+			name: "clone mode from a label",
+			labels: map[string]string{
+				restoreModeKey: "clone",
+			},
+			want: restoreConfig{
+				mode:               restoreModeClone,
+				ipConflictPolicy:   conflictPolicyFail,
+				macConflictPolicy:  conflictPolicyFail,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "annotation takes precedence over the label",
+			labels: map[string]string{
+				restoreModeKey: "clone",
+			},
+			annotations: map[string]string{
+				restoreModeKey: "restore",
+			},
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyFail,
+				macConflictPolicy:  conflictPolicyFail,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid restore mode",
+			labels: map[string]string{
+				restoreModeKey: "copy",
+			},
+			wantErr: true,
+		},
+		{
+			name: "CIDR mappings",
+			annotations: map[string]string{
+				cidrMappingAnnotation: `[{"from": "10.16.0.0/16", "to": "10.116.0.0/16"}, {"from": "10.17.0.0/16", "to": "10.117.0.0/16", "network": "test-ns/test-nad"}, {"from": "10.18.0.0/16", "to": "10.118.0.0/16", "network": "test-nad"}]`,
+			},
+			want: restoreConfig{
+				mode:              restoreModeRestore,
+				ipConflictPolicy:  conflictPolicyFail,
+				macConflictPolicy: conflictPolicyFail,
+				cidrMappings: []cidrMapping{
+					{
+						network: "",
+						mapping: mustCIDRMapping(t, "10.16.0.0/16", "10.116.0.0/16"),
+					},
+					{
+						network: "test-ns/test-nad",
+						mapping: mustCIDRMapping(t, "10.17.0.0/16", "10.117.0.0/16"),
+					},
+					{
+						network: "test-nad",
+						mapping: mustCIDRMapping(t, "10.18.0.0/16", "10.118.0.0/16"),
+					},
+				},
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "CIDR mappings with an invalid CIDR",
+			annotations: map[string]string{
+				cidrMappingAnnotation: `[{"from": "10.16.0.0/16", "to": "10.116.0.0/24"}]`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "CIDR mappings with an invalid network",
+			annotations: map[string]string{
+				cidrMappingAnnotation: `[{"from": "10.16.0.0/16", "to": "10.116.0.0/16", "network": "test-ns/test-nad/extra"}]`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "CIDR mappings that aren't JSON",
+			annotations: map[string]string{
+				cidrMappingAnnotation: "10.16.0.0/16=10.116.0.0/16",
+			},
+			wantErr: true,
+		},
+		{
+			name: "IP reservation",
+			annotations: map[string]string{
+				reserveIPsAnnotation: "true",
+			},
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyFail,
+				macConflictPolicy:  conflictPolicyFail,
+				reserveIPs:         true,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid IP reservation",
+			annotations: map[string]string{
+				reserveIPsAnnotation: "sure",
+			},
+			wantErr: true,
+		},
+		{
+			name: "wait for network with a custom timeout",
+			annotations: map[string]string{
+				waitForNetworkAnnotation:     "true",
+				networkWaitTimeoutAnnotation: "90s",
+			},
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyFail,
+				macConflictPolicy:  conflictPolicyFail,
+				waitForNetwork:     true,
+				networkWaitTimeout: 90 * time.Second,
+				ipRestorePolicy:    ipRestorePolicySkip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "verification report stored in a ConfigMap",
+			annotations: map[string]string{
+				verificationConfigMapAnnotation: "network-report",
+			},
+			want: restoreConfig{
+				mode:                  restoreModeRestore,
+				ipConflictPolicy:      conflictPolicyFail,
+				macConflictPolicy:     conflictPolicyFail,
+				networkWaitTimeout:    defaultNetworkWaitTimeout,
+				ipRestorePolicy:       ipRestorePolicySkip,
+				verifyNetwork:         true,
+				verificationConfigMap: "network-report",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid network verification",
+			annotations: map[string]string{
+				verifyNetworkAnnotation: "yes please",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid wait for network timeout",
+			annotations: map[string]string{
+				networkWaitTimeoutAnnotation: "-1m",
+			},
+			wantErr: true,
+		},
+		{
+			name: "rewrite captured IPs",
+			annotations: map[string]string{
+				ipRestorePolicyAnnotation: "rewrite",
+			},
+			want: restoreConfig{
+				mode:               restoreModeRestore,
+				ipConflictPolicy:   conflictPolicyFail,
+				macConflictPolicy:  conflictPolicyFail,
+				networkWaitTimeout: defaultNetworkWaitTimeout,
+				ipRestorePolicy:    ipRestorePolicyRewrite,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid IP restore policy",
+			annotations: map[string]string{
+				ipRestorePolicyAnnotation: "verbatim",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid IP conflict policy",
+			annotations: map[string]string{
+				ipConflictPolicyAnnotation: "ignore",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      tt.labels,
+					Annotations: tt.annotations,
+				},
+			}
+
+			got, err := newRestoreConfig(restore)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newRestoreConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.mode != tt.want.mode || got.ipConflictPolicy != tt.want.ipConflictPolicy || got.macConflictPolicy != tt.want.macConflictPolicy || got.reserveIPs != tt.want.reserveIPs {
+				t.Errorf("newRestoreConfig() got = %+v, want %+v", *got, tt.want)
+			}
+			if got.waitForNetwork != tt.want.waitForNetwork || got.networkWaitTimeout != tt.want.networkWaitTimeout || got.verifyNetwork != tt.want.verifyNetwork || got.verificationConfigMap != tt.want.verificationConfigMap || got.ipRestorePolicy != tt.want.ipRestorePolicy {
+				t.Errorf("newRestoreConfig() got = %+v, want %+v", *got, tt.want)
+			}
+			if len(got.cidrMappings) != len(tt.want.cidrMappings) {
+				t.Errorf("newRestoreConfig() got %d CIDR mappings, want %d", len(got.cidrMappings), len(tt.want.cidrMappings))
+			}
+			for i, want := range tt.want.cidrMappings {
+				mapping := got.cidrMappings[i]
+				if mapping.network != want.network || mapping.mapping.From.String() != want.mapping.From.String() || mapping.mapping.To.String() != want.mapping.To.String() {
+					t.Errorf("newRestoreConfig() got CIDR mapping %+v, want %+v", mapping, want)
+				}
+			}
+		})
+	}
+}
+
+func mustCIDRMapping(t *testing.T, from, to string) u.CIDRMapping {
+	mapping, err := u.NewCIDRMapping(from, to)
+	if err != nil {
+		t.Fatalf("failed to create CIDR mapping: %v", err)
+	}
+
+	return *mapping
+}
@@ -40,6 +40,12 @@ func (v *VMBackupItemAction) Execute(item runtime.Unstructured, backup *velerov1
 		return nil, nil, fmt.Errorf("backup object is nil")
 	}
 
+	// Read the settings of the backup
+	config, err := newBackupConfig(backup)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
 	// Retrieve the VM we are trying to backup
 	vm := new(kvcore.VirtualMachine)
 	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.UnstructuredContent(), vm); err != nil {
@@ -70,11 +76,20 @@ func (v *VMBackupItemAction) Execute(item runtime.Unstructured, backup *velerov1
 		}
 	}
 
-	// Retrieve the annotations to persist the MAC/IPs of the VM
-	annotations, err := u.GetKubeovnAnnotationsForVM(vm)
+	// Retrieve the identity of the VM, to persist its MAC/IPs and back up the subnets they are allocated from
+	netInfos, skipped, err := u.GetNetInfoForVm(vm, config.trustPersisted())
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	logNetInfoSources(v.log, netInfos, vm.Namespace, vm.Name)
+	logSkippedNetworks(v.log, skipped, vm.Namespace, vm.Name)
+
+	// The virt-launcher pod of a running VM carries the effective settings of its interfaces, like its security groups
+	podAnnotations, err := u.GetLauncherPodAnnotations(vm.Name, vm.Namespace)
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
+	annotations := u.NetInfosToAnnotations(config.netInfosToPersist(netInfos), podAnnotations)
 
 	// Copy the annotations to the VM
 	if vm.Spec.Template.ObjectMeta.Annotations == nil {
@@ -84,13 +99,40 @@ func (v *VMBackupItemAction) Execute(item runtime.Unstructured, backup *velerov1
 		vm.Spec.Template.ObjectMeta.Annotations[k] = v
 	}
 
+	// Back up the network objects the VM depends on along with it
+	additionalItems, err := backupAdditionalItems(netInfos, vm.Spec.Template.Spec.Networks, vm.Name, vm.Namespace, vm.Spec.Template.ObjectMeta.Annotations)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
 	vmUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vm)
 
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
 
-	return &unstructured.Unstructured{Object: vmUnstructured}, nil, nil
+	return &unstructured.Unstructured{Object: vmUnstructured}, additionalItems, nil
+}
+
+// logSkippedNetworks logs the Multus networks of a VM or VMI whose identity isn't persisted. The unsupported ones get
+// new addresses from Kube-OVN on restore, so they are reported as warnings.
+func logSkippedNetworks(log logrus.FieldLogger, skipped []u.SkippedNetwork, namespace, name string) {
+	for _, network := range skipped {
+		if network.Unsupported {
+			log.Warnf("Not persisting the identity of network %s of %s/%s, it gets new addresses on restore: %s", network.Name, namespace, name, network.Reason)
+			continue
+		}
+		log.Infof("Not persisting the identity of network %s of %s/%s: %s", network.Name, namespace, name, network.Reason)
+	}
+}
+
+// logNetInfoSources logs the interfaces of a VM or VMI whose identity wasn't read from their Kube-OVN IP
+func logNetInfoSources(log logrus.FieldLogger, netInfos []u.NetInfo, namespace, name string) {
+	for _, netInfo := range netInfos {
+		if netInfo.Source != u.NetInfoSourceIP {
+			log.Warnf("No Kube-OVN IP found for %s of %s/%s, persisting the identity read from %s", netInfo.NADAnnotation, namespace, name, netInfo.Source)
+		}
+	}
 }
 
 //////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
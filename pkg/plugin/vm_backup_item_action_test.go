@@ -2,24 +2,56 @@ package plugin
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 	"github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
 	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
 	"github.com/sirupsen/logrus"
 	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
 	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 	kvcore "kubevirt.io/api/core/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
 )
 
 func TestExecute(t *testing.T) {
+	// Mock GetNetworkClient, the Kube-OVN NADs don't exist and rely on the default provider
+	originalGetNetworkClient := u.GetNetworkClient
+	defer func() { u.GetNetworkClient = originalGetNetworkClient }()
+	nadClient := nadfake.NewSimpleClientset()
+	_, _ = nadClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions("test-ns").Create(context.Background(), &nadv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "sriov-nad", Namespace: "test-ns"},
+		Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.1", "type": "sriov", "vlan": 100}`},
+	}, metav1.CreateOptions{})
+	u.GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadClient, nil
+	}
+
 	// Mock GetKubeOvnClient
 	originalGetKubeOvnClient := u.GetKubeOvnClient
 	defer func() { u.GetKubeOvnClient = originalGetKubeOvnClient }()
 
+	// Mock GetKubeClient
+	originalGetKubeClient := u.GetKubeClient
+	defer func() { u.GetKubeClient = originalGetKubeClient }()
+
+	// Mock GetVMI, the VMs are stopped
+	originalGetVMI := u.GetVMI
+	defer func() { u.GetVMI = originalGetVMI }()
+	u.GetVMI = func(namespace, name string) (*kvcore.VirtualMachineInstance, error) {
+		return nil, apierrors.NewNotFound(kvcore.Resource("virtualmachineinstances"), name)
+	}
+
 	// Mock isVMIExcludedByLabel
 	originalIsVMIExcludedByLabel := isVMIExcludedByLabel
 	defer func() { isVMIExcludedByLabel = originalIsVMIExcludedByLabel }()
@@ -32,8 +64,10 @@ func TestExecute(t *testing.T) {
 		vm              *kvcore.VirtualMachine
 		backup          *velerov1api.Backup
 		existingIPs     []*v1.IP
+		launcherPods    []*corev1.Pod
 		excluded        bool
 		wantAnnotations map[string]string
+		wantAdditional  []velero.ResourceIdentifier
 		wantErr         bool
 	}{
 		{
@@ -192,6 +226,7 @@ func TestExecute(t *testing.T) {
 						Name: "test-vm.test-ns.nad1.test-ns.ovn",
 					},
 					Spec: v1.IPSpec{
+						Subnet:      "secondary-subnet",
 						V4IPAddress: "10.0.0.11",
 						MacAddress:  "00:00:00:00:00:11",
 					},
@@ -201,6 +236,7 @@ func TestExecute(t *testing.T) {
 						Name: "test-vm.test-ns.nad2.test-ns.ovn",
 					},
 					Spec: v1.IPSpec{
+						Subnet:      "secondary-subnet",
 						V4IPAddress: "10.0.0.22",
 						MacAddress:  "00:00:00:00:00:22",
 					},
@@ -210,18 +246,40 @@ func TestExecute(t *testing.T) {
 						Name: "test-vm.test-ns",
 					},
 					Spec: v1.IPSpec{
+						Subnet:      "ovn-default",
 						V4IPAddress: "10.0.0.1",
 						MacAddress:  "00:00:00:00:00:01",
 					},
 				},
 			},
+			launcherPods: []*corev1.Pod{
+				newLauncherPod("test-vm", "test-ns", corev1.PodRunning, map[string]string{
+					"ovn.kubernetes.io/security_groups":              "sg-web",
+					"nad1.test-ns.ovn.kubernetes.io/egress_rate":     "100",
+					"nad2.test-ns.ovn.kubernetes.io/security_groups": "sg-ssh,sg-web",
+				}),
+				newLauncherPod("other-vm", "test-ns", corev1.PodRunning, map[string]string{
+					"ovn.kubernetes.io/security_groups": "sg-other",
+				}),
+			},
 			wantAnnotations: map[string]string{
-				"nad1.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.11",
-				"nad1.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:11",
-				"nad2.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.22",
-				"nad2.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:22",
-				"ovn.kubernetes.io/ip_address":               "10.0.0.1",
-				"ovn.kubernetes.io/mac_address":              "00:00:00:00:00:01",
+				"nad1.test-ns.ovn.kubernetes.io/ip_address":      "10.0.0.11",
+				"nad1.test-ns.ovn.kubernetes.io/mac_address":     "00:00:00:00:00:11",
+				"nad2.test-ns.ovn.kubernetes.io/ip_address":      "10.0.0.22",
+				"nad2.test-ns.ovn.kubernetes.io/mac_address":     "00:00:00:00:00:22",
+				"nad1.test-ns.ovn.kubernetes.io/egress_rate":     "100",
+				"nad2.test-ns.ovn.kubernetes.io/security_groups": "sg-ssh,sg-web",
+				"ovn.kubernetes.io/ip_address":                   "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
+				"ovn.kubernetes.io/security_groups":              "sg-web",
+			},
+			wantAdditional: []velero.ResourceIdentifier{
+				{GroupResource: subnetsResource, Name: "ovn-default"},
+				{GroupResource: subnetsResource, Name: "secondary-subnet"},
+				{GroupResource: nadsResource, Namespace: "test-ns", Name: "nad1"},
+				{GroupResource: nadsResource, Namespace: "test-ns", Name: "nad2"},
+				{GroupResource: securityGroupsResource, Name: "sg-ssh"},
+				{GroupResource: securityGroupsResource, Name: "sg-web"},
 			},
 			wantErr: false,
 		},
@@ -270,6 +328,9 @@ func TestExecute(t *testing.T) {
 				"nad-primary.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.33",
 				"nad-primary.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:33",
 			},
+			wantAdditional: []velero.ResourceIdentifier{
+				{GroupResource: nadsResource, Namespace: "test-ns", Name: "nad-primary"},
+			},
 			wantErr: false,
 		},
 		{
@@ -327,6 +388,177 @@ func TestExecute(t *testing.T) {
 				"nad-secondary.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.44",
 				"nad-secondary.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:44",
 			},
+			wantAdditional: []velero.ResourceIdentifier{
+				{GroupResource: nadsResource, Namespace: "test-ns", Name: "nad-secondary"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing IP falls back to the persisted annotations",
+			vm: &kvcore.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: kvcore.VirtualMachineSpec{
+					Template: &kvcore.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"ovn.kubernetes.io/ip_address":     "10.0.0.1",
+								"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:01",
+								"ovn.kubernetes.io/logical_switch": "ovn-default",
+							},
+						},
+						Spec: kvcore.VirtualMachineInstanceSpec{
+							Networks: []kvcore.Network{},
+						},
+					},
+				},
+			},
+			backup: &velerov1api.Backup{
+				Spec: velerov1api.BackupSpec{
+					IncludedResources: []string{"*"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":     "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:01",
+				"ovn.kubernetes.io/logical_switch": "ovn-default",
+			},
+			wantAdditional: []velero.ResourceIdentifier{
+				{GroupResource: subnetsResource, Name: "ovn-default"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Authoritative persisted identity is kept unchanged",
+			vm: &kvcore.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: kvcore.VirtualMachineSpec{
+					Template: &kvcore.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"ovn.kubernetes.io/ip_address":      "10.0.0.1",
+								"ovn.kubernetes.io/mac_address":     "00:00:00:00:00:01",
+								"ovn.kubernetes.io/security_groups": "sg-persisted",
+							},
+						},
+						Spec: kvcore.VirtualMachineInstanceSpec{
+							Networks: []kvcore.Network{},
+						},
+					},
+				},
+			},
+			backup: &velerov1api.Backup{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{persistedIdentityAnnotation: "authoritative"},
+				},
+				Spec: velerov1api.BackupSpec{
+					IncludedResources: []string{"*"},
+				},
+			},
+			launcherPods: []*corev1.Pod{
+				newLauncherPod("test-vm", "test-ns", corev1.PodRunning, map[string]string{
+					"ovn.kubernetes.io/security_groups": "sg-live",
+				}),
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":      "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":     "00:00:00:00:00:01",
+				"ovn.kubernetes.io/security_groups": "sg-persisted",
+			},
+			wantAdditional: []velero.ResourceIdentifier{
+				{GroupResource: securityGroupsResource, Name: "sg-persisted"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid persisted identity mode",
+			vm: &kvcore.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+			},
+			backup: &velerov1api.Backup{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{persistedIdentityAnnotation: "ignore"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing IP without any other source fails the backup",
+			vm: &kvcore.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: kvcore.VirtualMachineSpec{
+					Template: &kvcore.VirtualMachineInstanceTemplateSpec{
+						Spec: kvcore.VirtualMachineInstanceSpec{
+							Networks: []kvcore.Network{},
+						},
+					},
+				},
+			},
+			backup: &velerov1api.Backup{
+				Spec: velerov1api.BackupSpec{
+					IncludedResources: []string{"*"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Multus secondary not managed by Kube-OVN is skipped",
+			vm: &kvcore.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: kvcore.VirtualMachineSpec{
+					Template: &kvcore.VirtualMachineInstanceTemplateSpec{
+						Spec: kvcore.VirtualMachineInstanceSpec{
+							Networks: []kvcore.Network{
+								{
+									Name: "sriov",
+									NetworkSource: kvcore.NetworkSource{
+										Multus: &kvcore.MultusNetwork{
+											NetworkName: "sriov-nad",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			backup: &velerov1api.Backup{
+				Spec: velerov1api.BackupSpec{
+					IncludedResources: []string{"*"},
+				},
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						V4IPAddress: "10.0.0.1",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantAdditional: []velero.ResourceIdentifier{
+				{GroupResource: nadsResource, Namespace: "test-ns", Name: "sriov-nad"},
+			},
 			wantErr: false,
 		},
 	}
@@ -342,6 +574,15 @@ func TestExecute(t *testing.T) {
 				return fakeClient, nil
 			}
 
+			// Set up fake Kubernetes client
+			fakeKubeClient := kubefake.NewSimpleClientset()
+			for _, pod := range tt.launcherPods {
+				_, _ = fakeKubeClient.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+			}
+			u.GetKubeClient = func() (kubernetes.Interface, error) {
+				return fakeKubeClient, nil
+			}
+
 			// Mock isVMIExcludedByLabel
 			isVMIExcludedByLabel = func(vm *kvcore.VirtualMachine) (bool, error) {
 				return tt.excluded, nil
@@ -355,7 +596,7 @@ func TestExecute(t *testing.T) {
 
 			obj := &unstructured.Unstructured{Object: vmUnstructured}
 
-			got, _, err := action.Execute(obj, tt.backup)
+			got, additionalItems, err := action.Execute(obj, tt.backup)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -380,7 +621,28 @@ func TestExecute(t *testing.T) {
 						t.Errorf("Execute() expected annotation %s=%s, got %s", k, v, annotations[k])
 					}
 				}
+
+				if !reflect.DeepEqual(additionalItems, tt.wantAdditional) {
+					t.Errorf("Execute() got additional items %v, want %v", additionalItems, tt.wantAdditional)
+				}
 			}
 		})
 	}
 }
+
+// newLauncherPod returns a virt-launcher pod controlled by a VMI
+func newLauncherPod(vmiName, namespace string, phase corev1.PodPhase, annotations map[string]string) *corev1.Pod {
+	isController := true
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "virt-launcher-" + vmiName + "-" + string(phase),
+			Namespace:   namespace,
+			Labels:      map[string]string{"kubevirt.io": "virt-launcher"},
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "kubevirt.io/v1", Kind: "VirtualMachineInstance", Name: vmiName, Controller: &isController},
+			},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+type VMRestoreItemAction struct {
+	log logrus.FieldLogger
+}
+
+func NewVMRestoreItemAction(logger logrus.FieldLogger) *VMRestoreItemAction {
+	return &VMRestoreItemAction{
+		log: logger,
+	}
+}
+
+func (r *VMRestoreItemAction) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: []string{"virtualmachines.kubevirt.io"},
+	}, nil
+}
+
+func (r *VMRestoreItemAction) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	r.log.Info("Executing VMRestoreItemAction")
+
+	// No restore, errors out
+	if input == nil || input.Restore == nil {
+		return nil, fmt.Errorf("restore object is nil")
+	}
+
+	// Read the settings of the restore
+	config, err := newRestoreConfig(input.Restore)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Retrieve the VM we are trying to restore
+	vm := new(kvcore.VirtualMachine)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(input.Item.UnstructuredContent(), vm); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Without a template, there is no network identity to restore
+	if vm.Spec.Template == nil {
+		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
+	}
+
+	// Check the persisted identity of the VM against the target cluster, or drop it for clones
+	restorer := &identityRestorer{log: r.log}
+	if err := restorer.restore(restoredVM(vm), input.Restore, config); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	output, err := r.output(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let Velero follow the VM until it comes up with its persisted identity, or until its identity can be verified
+	if config.mode != restoreModeClone && (config.waitForNetwork || config.verifyNetwork) && shouldWaitForNetwork(vm) {
+		namespace := targetNamespace(vm.Namespace, input.Restore.Spec.NamespaceMapping)
+		output = output.WithOperationID(newOperationID(namespace, vm.Name, time.Now()))
+	}
+
+	return output, nil
+}
+
+// output converts the restored VM back into the output of the restore action
+func (r *VMRestoreItemAction) output(vm *kvcore.VirtualMachine) (*velero.RestoreItemActionExecuteOutput, error) {
+	vmUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vm)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return velero.NewRestoreItemActionExecuteOutput(&unstructured.Unstructured{Object: vmUnstructured}), nil
+}
@@ -0,0 +1,823 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	"github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kvcore "kubevirt.io/api/core/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
+)
+
+func TestRestoreExecute(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider, except for one with a custom provider
+	originalGetNetworkClient := u.GetNetworkClient
+	defer func() { u.GetNetworkClient = originalGetNetworkClient }()
+	nadClient := nadfake.NewSimpleClientset()
+	_, _ = nadClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions("test-ns").Create(context.Background(), &nadv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-nad", Namespace: "test-ns"},
+		Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.0", "type": "kube-ovn", "provider": "tenant.ovn"}`},
+	}, metav1.CreateOptions{})
+	u.GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadClient, nil
+	}
+
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := u.GetKubeOvnClient
+	defer func() { u.GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	// Mock ListVMIs
+	originalListVMIs := u.ListVMIs
+	defer func() { u.ListVMIs = originalListVMIs }()
+
+	logger := logrus.New()
+	action := NewVMRestoreItemAction(logger)
+
+	subnets := []*v1.Subnet{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "ovn-default",
+			},
+			Spec: v1.SubnetSpec{
+				CIDRBlock: "10.0.0.0/24",
+			},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		annotations      map[string]string
+		networks         []kvcore.Network
+		interfaces       []kvcore.Interface
+		existingIPs      []*v1.IP
+		existingVMIs     []kvcore.VirtualMachineInstance
+		restore          *velerov1api.Restore
+		wantAnnotations  map[string]string
+		wantNetworkNames []string
+		wantMACAddresses []string
+		wantReservedIPs  []string
+		// wantUnreservedIPs are the IPs that must not be created by the restore
+		wantUnreservedIPs []string
+		wantOperation     bool
+		wantErr           bool
+	}{
+		{
+			name: "Valid identity is kept",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+				"existing.annotation":           "preserved",
+			},
+			restore: &velerov1api.Restore{},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+				"existing.annotation":           "preserved",
+			},
+			wantErr: false,
+		},
+		{
+			name: "IP address outside of every subnet is dropped",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "192.168.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			restore: &velerov1api.Restore{},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Logical switch is kept",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":     "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:01",
+				"ovn.kubernetes.io/logical_switch": "ovn-default",
+			},
+			restore: &velerov1api.Restore{},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":     "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:01",
+				"ovn.kubernetes.io/logical_switch": "ovn-default",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing logical switch is dropped along with the IP address",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":     "192.168.0.1",
+				"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:01",
+				"ovn.kubernetes.io/logical_switch": "missing-subnet",
+			},
+			restore: &velerov1api.Restore{},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Malformed identity is dropped",
+			annotations: map[string]string{
+				"nad.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.300",
+				"nad.test-ns.ovn.kubernetes.io/mac_address": "not-a-mac",
+			},
+			restore:         &velerov1api.Restore{},
+			wantAnnotations: map[string]string{},
+			wantErr:         false,
+		},
+		{
+			name: "Namespace mapping rewrites NAD annotations and network names",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                 "10.0.0.1",
+				"test-nad.old-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			networks: []kvcore.Network{
+				{
+					Name: "default",
+					NetworkSource: kvcore.NetworkSource{
+						Pod: &kvcore.PodNetwork{},
+					},
+				},
+				{
+					Name: "secondary",
+					NetworkSource: kvcore.NetworkSource{
+						Multus: &kvcore.MultusNetwork{
+							NetworkName: "old-ns/test-nad",
+						},
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"old-ns": "new-ns"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                 "10.0.0.1",
+				"test-nad.new-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			wantNetworkNames: []string{"", "new-ns/test-nad"},
+			wantErr:          false,
+		},
+		{
+			name: "IP conflict fails the restore by default",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "other-vm",
+						Namespace:   "test-ns",
+						Subnet:      "ovn-default",
+						V4IPAddress: "10.0.0.1",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{},
+			wantErr: true,
+		},
+		{
+			name: "IP conflict with the original VM fails a restore into a mapped namespace",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "test-vm",
+						Namespace:   "test-ns",
+						Subnet:      "ovn-default",
+						V4IPAddress: "10.0.0.1",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "new-ns"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "IP conflict strips the identity",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+				"existing.annotation":           "preserved",
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "other-vm",
+						Namespace:   "test-ns",
+						Subnet:      "ovn-default",
+						V4IPAddress: "10.0.0.1",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ipConflictPolicyAnnotation: "strip"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"existing.annotation": "preserved",
+			},
+			wantErr: false,
+		},
+		{
+			name: "IP conflict keeps the identity",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "other-vm",
+						Namespace:   "test-ns",
+						Subnet:      "ovn-default",
+						V4IPAddress: "10.0.0.1",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ipConflictPolicyAnnotation: "keep"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantErr: false,
+		},
+		{
+			name: "MAC conflict with a VMI fails the restore by default",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingVMIs: []kvcore.VirtualMachineInstance{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "other-vm",
+						Namespace: "test-ns",
+					},
+					Status: kvcore.VirtualMachineInstanceStatus{
+						Interfaces: []kvcore.VirtualMachineInstanceNetworkInterface{
+							{
+								Name: "default",
+								MAC:  "00:00:00:00:00:01",
+								IPs:  []string{"10.0.0.2"},
+							},
+						},
+					},
+				},
+			},
+			restore: &velerov1api.Restore{},
+			wantErr: true,
+		},
+		{
+			name: "MAC conflict with the original VM fails a restore into a mapped namespace",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingVMIs: []kvcore.VirtualMachineInstance{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-vm",
+						Namespace: "test-ns",
+					},
+					Status: kvcore.VirtualMachineInstanceStatus{
+						Interfaces: []kvcore.VirtualMachineInstanceNetworkInterface{
+							{
+								Name: "default",
+								MAC:  "00:00:00:00:00:01",
+								IPs:  []string{"10.0.0.2"},
+							},
+						},
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "new-ns"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "MAC conflict with an IP strips the MAC address",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "other-vm",
+						Namespace:   "test-ns",
+						Subnet:      "ovn-default",
+						V4IPAddress: "10.0.0.2",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{macConflictPolicyAnnotation: "strip"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Same MAC on another logical switch is not a conflict",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-vm.other-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "other-vm",
+						Namespace:   "other-ns",
+						Subnet:      "other-subnet",
+						V4IPAddress: "10.1.0.2",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Clone mode drops the identity",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                   "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.2",
+				"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:02",
+				"ovn.kubernetes.io/logical_switch":               "ovn-default",
+				"existing.annotation":                            "preserved",
+			},
+			interfaces: []kvcore.Interface{
+				{
+					Name:       "default",
+					MacAddress: "00:00:00:00:00:01",
+				},
+				{
+					Name: "secondary",
+				},
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "test-vm",
+						Namespace:   "original-ns",
+						Subnet:      "ovn-default",
+						V4IPAddress: "10.0.0.1",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{restoreModeKey: "clone"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/logical_switch": "ovn-default",
+				"existing.annotation":              "preserved",
+			},
+			wantMACAddresses: []string{"", ""},
+			wantErr:          false,
+		},
+		{
+			name: "CIDR mapping translates the identity into the target subnet",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                  "10.16.0.1",
+				"ovn.kubernetes.io/mac_address":                 "00:00:00:00:00:01",
+				"ovn.kubernetes.io/logical_switch":              "source-default",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.17.0.2",
+			},
+			networks: testNetworks("test-ns/test-nad"),
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						cidrMappingAnnotation: `[{"from": "10.16.0.0/24", "to": "10.0.0.0/24"}, {"from": "10.17.0.0/24", "to": "10.0.0.0/24", "network": "test-ns/test-nad"}]`,
+					},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                 "00:00:00:00:00:01",
+				"ovn.kubernetes.io/logical_switch":              "ovn-default",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "CIDR mapping of a network doesn't apply to the other networks",
+			annotations: map[string]string{
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			networks: testNetworks("test-ns/test-nad"),
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						cidrMappingAnnotation: `[{"from": "10.0.0.2/32", "to": "10.0.0.3/32", "network": "test-ns/other-nad"}]`,
+					},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			wantNetworkNames: []string{"", "test-ns/test-nad"},
+			wantErr:          false,
+		},
+		{
+			name: "CIDR mapping of a bare network name follows the custom provider of its NAD",
+			annotations: map[string]string{
+				"tenant.ovn.kubernetes.io/ip_address": "10.17.0.2",
+			},
+			networks: testNetworks("custom-nad"),
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						cidrMappingAnnotation: `[{"from": "10.17.0.0/24", "to": "10.0.0.0/24", "network": "custom-nad"}]`,
+					},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"tenant.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "CIDR mapping references the network as named in the backup of a VM restored into a mapped namespace",
+			annotations: map[string]string{
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.17.0.2",
+			},
+			networks: testNetworks("test-ns/test-nad"),
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						cidrMappingAnnotation: `[{"from": "10.17.0.0/24", "to": "10.0.0.0/24", "network": "test-ns/test-nad"}]`,
+					},
+				},
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "new-ns"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"test-nad.new-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			wantNetworkNames: []string{"", "new-ns/test-nad"},
+			wantErr:          false,
+		},
+		{
+			name: "Persisted IP addresses are reserved",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                   "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "192.168.0.1",
+				"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:02",
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-restore",
+					Annotations: map[string]string{reserveIPsAnnotation: "true"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                   "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
+				"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:02",
+			},
+			wantReservedIPs: []string{"test-vm.test-ns"},
+			wantErr:         false,
+		},
+		{
+			name: "Persisted IP addresses kept despite a conflict are not reserved",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						PodName:     "other-vm",
+						Namespace:   "test-ns",
+						Subnet:      "ovn-default",
+						V4IPAddress: "10.0.0.1",
+					},
+				},
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-restore",
+					Annotations: map[string]string{
+						ipConflictPolicyAnnotation: "keep",
+						reserveIPsAnnotation:       "true",
+					},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantUnreservedIPs: []string{"test-vm.test-ns"},
+			wantErr:           false,
+		},
+		{
+			name: "Persisted IP addresses are reserved in the mapped namespace",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-restore",
+					Annotations: map[string]string{reserveIPsAnnotation: "true"},
+				},
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "new-ns"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantReservedIPs: []string{"test-vm.new-ns"},
+			wantErr:         false,
+		},
+		{
+			name: "Waiting for the network starts an operation",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{waitForNetworkAnnotation: "true"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantOperation: true,
+			wantErr:       false,
+		},
+		{
+			name: "Waiting for the network follows the VM into its mapped namespace",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{waitForNetworkAnnotation: "true"},
+				},
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "new-ns"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantOperation: true,
+			wantErr:       false,
+		},
+		{
+			name: "Verifying the network starts an operation",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{verifyNetworkAnnotation: "true"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantOperation: true,
+			wantErr:       false,
+		},
+		{
+			name: "Invalid conflict policy",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ipConflictPolicyAnnotation: "ignore"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Nil restore object",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+			restore: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up fake Kube-OVN client
+			fakeClient := fake.NewSimpleClientset()
+			for _, subnet := range subnets {
+				_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), subnet, metav1.CreateOptions{})
+			}
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			u.GetKubeOvnClient = func() (u.KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+			u.ListVMIs = func() ([]kvcore.VirtualMachineInstance, error) {
+				return tt.existingVMIs, nil
+			}
+
+			runStrategy := kvcore.RunStrategyAlways
+			vm := &kvcore.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: kvcore.VirtualMachineSpec{
+					RunStrategy: &runStrategy,
+					Template: &kvcore.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: tt.annotations,
+						},
+						Spec: kvcore.VirtualMachineInstanceSpec{
+							Domain: kvcore.DomainSpec{
+								Devices: kvcore.Devices{
+									Interfaces: tt.interfaces,
+								},
+							},
+							Networks: tt.networks,
+						},
+					},
+				},
+			}
+
+			// Convert VM to Unstructured
+			vmUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vm)
+			if err != nil {
+				t.Fatalf("failed to convert VM to unstructured: %v", err)
+			}
+
+			obj := &unstructured.Unstructured{Object: vmUnstructured}
+
+			got, err := action.Execute(&velero.RestoreItemActionExecuteInput{
+				Item:           obj,
+				ItemFromBackup: obj,
+				Restore:        tt.restore,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				if got == nil || got.UpdatedItem == nil {
+					t.Errorf("Execute() returned nil item")
+					return
+				}
+
+				if (got.OperationID != "") != tt.wantOperation {
+					t.Errorf("Execute() got operation ID %q, wantOperation %v", got.OperationID, tt.wantOperation)
+				}
+				if got.OperationID != "" {
+					namespace, _, _, err := parseOperationID(got.OperationID)
+					if wantNamespace := targetNamespace(vm.Namespace, tt.restore.Spec.NamespaceMapping); err != nil || namespace != wantNamespace {
+						t.Errorf("Execute() got operation ID %q, want it to target namespace %s", got.OperationID, wantNamespace)
+					}
+				}
+
+				// Convert back to VM to check annotations
+				gotVM := new(kvcore.VirtualMachine)
+				err = runtime.DefaultUnstructuredConverter.FromUnstructured(got.UpdatedItem.UnstructuredContent(), gotVM)
+				if err != nil {
+					t.Fatalf("failed to convert returned item back to VM: %v", err)
+				}
+
+				annotations := gotVM.Spec.Template.ObjectMeta.Annotations
+				if len(annotations) != len(tt.wantAnnotations) {
+					t.Errorf("Execute() got annotations %v, want %v", annotations, tt.wantAnnotations)
+				}
+				for k, v := range tt.wantAnnotations {
+					if annotations[k] != v {
+						t.Errorf("Execute() expected annotation %s=%s, got %s", k, v, annotations[k])
+					}
+				}
+
+				for i, want := range tt.wantNetworkNames {
+					network := gotVM.Spec.Template.Spec.Networks[i]
+					if network.Multus != nil && network.Multus.NetworkName != want {
+						t.Errorf("Execute() expected network %s to reference %s, got %s", network.Name, want, network.Multus.NetworkName)
+					}
+				}
+
+				for _, name := range tt.wantReservedIPs {
+					ip, err := fakeClient.KubeovnV1().IPs().Get(context.Background(), name, metav1.GetOptions{})
+					if err != nil {
+						t.Errorf("Execute() expected IP %s to be reserved: %v", name, err)
+						continue
+					}
+					if ip.Labels[reservedByRestoreLabel] != tt.restore.Name {
+						t.Errorf("Execute() expected IP %s to be reserved by %s, got %s", name, tt.restore.Name, ip.Labels[reservedByRestoreLabel])
+					}
+					if wantNamespace := targetNamespace(vm.Namespace, tt.restore.Spec.NamespaceMapping); ip.Spec.Namespace != wantNamespace {
+						t.Errorf("Execute() expected IP %s to be reserved in namespace %s, got %s", name, wantNamespace, ip.Spec.Namespace)
+					}
+				}
+
+				for _, name := range tt.wantUnreservedIPs {
+					if _, err := fakeClient.KubeovnV1().IPs().Get(context.Background(), name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+						t.Errorf("Execute() expected IP %s not to be reserved, got error %v", name, err)
+					}
+				}
+
+				for i, want := range tt.wantMACAddresses {
+					iface := gotVM.Spec.Template.Spec.Domain.Devices.Interfaces[i]
+					if iface.MacAddress != want {
+						t.Errorf("Execute() expected interface %s to have MAC address %s, got %s", iface.Name, want, iface.MacAddress)
+					}
+				}
+			}
+		})
+	}
+}
+
+// testNetworks returns the pod network followed by a Multus network
+func testNetworks(networkName string) []kvcore.Network {
+	return []kvcore.Network{
+		{
+			Name:          "default",
+			NetworkSource: kvcore.NetworkSource{Pod: &kvcore.PodNetwork{}},
+		},
+		{
+			Name:          "secondary",
+			NetworkSource: kvcore.NetworkSource{Multus: &kvcore.MultusNetwork{NetworkName: networkName}},
+		},
+	}
+}
@@ -0,0 +1,222 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/label"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	riav2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/restoreitemaction/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+// The VMRestoreItemAction can start an asynchronous operation for every VM it restores. Velero then polls the
+// operation through Progress, until the VM comes up with its persisted network identity or the operation times out.
+// Once the operation completes, the identity of the VM can be verified and reported.
+// The operation ID carries everything needed to check the VM, as the plugin may be restarted between two polls.
+
+func (r *VMRestoreItemAction) Name() string {
+	return "VMRestoreItemAction"
+}
+
+func (r *VMRestoreItemAction) Progress(operationID string, restore *velerov1api.Restore) (velero.OperationProgress, error) {
+	progress := velero.OperationProgress{}
+
+	namespace, name, started, err := parseOperationID(operationID)
+	if err != nil {
+		return progress, riav2.InvalidOperationIDError(operationID)
+	}
+
+	config, err := newRestoreConfig(restore)
+	if err != nil {
+		return progress, err
+	}
+
+	vm, err := u.GetVM(namespace, name)
+	if err != nil {
+		return progress, fmt.Errorf("failed to retrieve VM %s/%s: %w", namespace, name, err)
+	}
+
+	expected := u.NetInfosFromAnnotations(vm.Spec.Template.ObjectMeta.Annotations)
+	pending, err := r.pendingInterfaces(vm, expected)
+	if err != nil {
+		return progress, err
+	}
+
+	progress.Started = started
+	progress.Updated = time.Now()
+	progress.OperationUnits = "interfaces"
+	progress.NTotal = int64(len(expected))
+	progress.NCompleted = int64(len(expected) - len(pending))
+
+	switch {
+	case len(pending) == 0:
+		progress.Completed = true
+		progress.Description = "VM is up with its persisted network identity"
+	case time.Since(started) > config.networkWaitTimeout:
+		progress.Completed = true
+		progress.Description = fmt.Sprintf("VM did not come up with its persisted network identity within %s", config.networkWaitTimeout)
+		// Without waiting for the network, the operation only delays the verification report and never fails the VM
+		if config.waitForNetwork {
+			progress.Err = fmt.Sprintf("VM %s/%s did not come up with its persisted network identity within %s: %s", namespace, name, config.networkWaitTimeout, strings.Join(pending, "; "))
+			r.log.Error(progress.Err)
+		}
+	default:
+		progress.Description = fmt.Sprintf("Waiting for %s", strings.Join(pending, "; "))
+	}
+
+	if progress.Completed && config.verifyNetwork {
+		if err := r.verifyNetwork(vm, restore, config); err != nil {
+			// The report is informative, failing to produce it must not fail the restore of the VM
+			r.log.Errorf("Failed to verify the network identity of VM %s/%s: %v", namespace, name, err)
+		}
+	}
+
+	return progress, nil
+}
+
+// verifyNetwork compares the persisted identity of a restored VM with the identity Kube-OVN allocated to it.
+// The report is logged interface by interface, and stored in a ConfigMap if the restore names one.
+func (r *VMRestoreItemAction) verifyNetwork(vm *kvcore.VirtualMachine, restore *velerov1api.Restore, config *restoreConfig) error {
+	report, err := u.VerifyVMNetwork(vm)
+	if err != nil {
+		return err
+	}
+
+	for _, verification := range report.Interfaces {
+		logger := r.log.WithFields(logrus.Fields{
+			"restore":     restore.Name,
+			"vm":          fmt.Sprintf("%s/%s", vm.Namespace, vm.Name),
+			"network":     verification.Network,
+			"status":      verification.Status,
+			"expectedMAC": verification.ExpectedMAC,
+			"expectedIPs": verification.ExpectedIPs,
+			"liveMAC":     verification.LiveMAC,
+			"liveIPs":     verification.LiveIPs,
+		})
+		if verification.Status == u.VerificationMatched {
+			logger.Info("Verified network identity of restored VM")
+		} else {
+			logger.Warn("Verified network identity of restored VM")
+		}
+	}
+
+	if report.Matched() {
+		r.log.Infof("Restored VM %s/%s got its persisted network identity back", vm.Namespace, vm.Name)
+	} else {
+		r.log.Warnf("Restored VM %s/%s did not get its persisted network identity back", vm.Namespace, vm.Name)
+	}
+
+	if config.verificationConfigMap == "" {
+		return nil
+	}
+
+	labels := map[string]string{velerov1api.RestoreNameLabel: label.GetValidName(restore.Name)}
+	return u.StoreVerificationReport(restore.Namespace, config.verificationConfigMap, labels, report)
+}
+
+// Cancel has nothing to do, as the operation only observes the VM
+func (r *VMRestoreItemAction) Cancel(operationID string, restore *velerov1api.Restore) error {
+	return nil
+}
+
+func (r *VMRestoreItemAction) AreAdditionalItemsReady(additionalItems []velero.ResourceIdentifier, restore *velerov1api.Restore) (bool, error) {
+	return true, nil
+}
+
+// pendingInterfaces describes the interfaces of a VM whose live identity doesn't match the persisted one yet.
+// Both the interfaces reported by the VMI and the Kube-OVN IP custom resources must match. The MAC address of the
+// interfaces that aren't bridged is only checked against the IP custom resources.
+func (r *VMRestoreItemAction) pendingInterfaces(vm *kvcore.VirtualMachine, expected []u.NetInfo) ([]string, error) {
+	vmi, err := u.GetVMI(vm.Namespace, vm.Name)
+	if apierrors.IsNotFound(err) {
+		return []string{"VMI to be created"}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve VMI %s/%s: %w", vm.Namespace, vm.Name, err)
+	}
+
+	reported := make(map[string]u.NetInfo)
+	for _, netInfo := range u.NetInfosFromVMIStatus(vmi) {
+		reported[netInfo.NADAnnotation] = netInfo
+	}
+
+	var pending []string
+	for _, netInfo := range expected {
+		live, ok := reported[netInfo.NADAnnotation]
+		// The VMI only reports the MAC address allocated by Kube-OVN for bridged interfaces, the IP checks the others
+		if ok && live.MAC == "" {
+			live.MAC = netInfo.MAC
+		}
+		if !ok || !netInfoMatches(&netInfo, &live) {
+			pending = append(pending, fmt.Sprintf("%s to be reported by the VMI", describeNetInfo(&netInfo)))
+			continue
+		}
+
+		ip, err := u.GetIPForVM(netInfo.NADAnnotation, vm.Name, vm.Namespace)
+		if err != nil {
+			pending = append(pending, fmt.Sprintf("%s to be allocated by Kube-OVN", describeNetInfo(&netInfo)))
+			continue
+		}
+		if allocated := u.IPToNetInfo(netInfo.NADAnnotation, *ip); !netInfoMatches(&netInfo, allocated) {
+			pending = append(pending, fmt.Sprintf("%s to be allocated by Kube-OVN, got %s", describeNetInfo(&netInfo), describeNetInfo(allocated)))
+		}
+	}
+
+	return pending, nil
+}
+
+// netInfoMatches checks whether a live identity matches the parts of the persisted identity that were restored
+func netInfoMatches(expected, live *u.NetInfo) bool {
+	if expected.MAC != "" && !expected.MACMatches(live) {
+		return false
+	}
+
+	return expected.IPs == "" || expected.IPsMatch(live)
+}
+
+// describeNetInfo describes the identity of an interface for humans
+func describeNetInfo(netInfo *u.NetInfo) string {
+	return fmt.Sprintf("%s (MAC %s, IP %s)", netInfo.NADAnnotation, netInfo.MAC, netInfo.IPs)
+}
+
+// shouldWaitForNetwork checks whether the restored VM will be started, and has a persisted identity to wait for
+func shouldWaitForNetwork(vm *kvcore.VirtualMachine) bool {
+	if len(u.NetInfosFromAnnotations(vm.Spec.Template.ObjectMeta.Annotations)) == 0 {
+		return false
+	}
+
+	runStrategy, err := vm.RunStrategy()
+	if err != nil {
+		return false
+	}
+
+	return runStrategy == kvcore.RunStrategyAlways || runStrategy == kvcore.RunStrategyRerunOnFailure || runStrategy == kvcore.RunStrategyOnce
+}
+
+// newOperationID builds the ID of the operation waiting for a VM, as [NS]/[VM]/[STARTED].
+// The namespace is the one the VM is restored into, where Progress finds it once Velero created it.
+func newOperationID(namespace, name string, started time.Time) string {
+	return fmt.Sprintf("%s/%s/%d", namespace, name, started.Unix())
+}
+
+// parseOperationID extracts the namespace and name of the VM, and the start of the operation from an operation ID
+func parseOperationID(operationID string) (string, string, time.Time, error) {
+	split := strings.Split(operationID, "/")
+	if len(split) != 3 {
+		return "", "", time.Time{}, fmt.Errorf("expected operation ID to have format [NS]/[VM]/[STARTED], got %s", operationID)
+	}
+
+	started, err := strconv.ParseInt(split[2], 10, 64)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("invalid start of operation %s: %w", operationID, err)
+	}
+
+	return split[0], split[1], time.Unix(started, 0), nil
+}
@@ -0,0 +1,410 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	riav2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/restoreitemaction/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+// The restore action must implement the v2 interface to be registered as such
+var _ riav2.RestoreItemAction = &VMRestoreItemAction{}
+
+func TestProgress(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := u.GetKubeOvnClient
+	defer func() { u.GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	// Mock GetKubeClient
+	originalGetKubeClient := u.GetKubeClient
+	defer func() { u.GetKubeClient = originalGetKubeClient }()
+
+	// Mock GetVM and GetVMI
+	originalGetVM := u.GetVM
+	defer func() { u.GetVM = originalGetVM }()
+	originalGetVMI := u.GetVMI
+	defer func() { u.GetVMI = originalGetVMI }()
+
+	logger := logrus.New()
+	action := NewVMRestoreItemAction(logger)
+
+	vm := &kvcore.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-vm",
+			Namespace: "test-ns",
+		},
+		Spec: kvcore.VirtualMachineSpec{
+			Template: &kvcore.VirtualMachineInstanceTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+						"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+					},
+				},
+			},
+		},
+	}
+
+	runningVMI := &kvcore.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-vm",
+			Namespace: "test-ns",
+		},
+		Spec: kvcore.VirtualMachineInstanceSpec{
+			Domain: kvcore.DomainSpec{
+				Devices: kvcore.Devices{
+					Interfaces: []kvcore.Interface{
+						{
+							Name:                   "default",
+							InterfaceBindingMethod: kvcore.InterfaceBindingMethod{Bridge: &kvcore.InterfaceBridge{}},
+						},
+					},
+				},
+			},
+		},
+		Status: kvcore.VirtualMachineInstanceStatus{
+			Interfaces: []kvcore.VirtualMachineInstanceNetworkInterface{
+				{
+					Name: "default",
+					MAC:  "00:00:00:00:00:01",
+					IPs:  []string{"10.0.0.1", "fe80::1"},
+				},
+			},
+		},
+	}
+
+	// The guest of a masquerade interface reports a MAC address of its own
+	masqueradeVMI := &kvcore.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-vm",
+			Namespace: "test-ns",
+		},
+		Spec: kvcore.VirtualMachineInstanceSpec{
+			Domain: kvcore.DomainSpec{
+				Devices: kvcore.Devices{
+					Interfaces: []kvcore.Interface{
+						{
+							Name:                   "default",
+							InterfaceBindingMethod: kvcore.InterfaceBindingMethod{Masquerade: &kvcore.InterfaceMasquerade{}},
+						},
+					},
+				},
+			},
+		},
+		Status: kvcore.VirtualMachineInstanceStatus{
+			Interfaces: []kvcore.VirtualMachineInstanceNetworkInterface{
+				{
+					Name: "default",
+					MAC:  "02:00:00:00:00:01",
+					IPs:  []string{"10.0.0.1"},
+				},
+			},
+		},
+	}
+
+	now := time.Now().Unix()
+	recentOperation := fmt.Sprintf("test-ns/test-vm/%d", now)
+	expiredOperation := fmt.Sprintf("test-ns/test-vm/%d", now-int64((time.Hour).Seconds()))
+
+	tests := []struct {
+		name               string
+		operationID        string
+		restoreAnnotations map[string]string
+		vmi                *kvcore.VirtualMachineInstance
+		existingIPs        []*v1.IP
+		wantCompleted      bool
+		wantFailed         bool
+		wantNCompleted     int64
+		wantReport         u.VerificationStatus
+		wantErr            bool
+	}{
+		{
+			name:        "VM is up with its persisted identity",
+			operationID: recentOperation,
+			vmi:         runningVMI,
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						V4IPAddress: "10.0.0.1",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			wantCompleted:  true,
+			wantFailed:     false,
+			wantNCompleted: 1,
+			wantErr:        false,
+		},
+		{
+			name:        "Masquerade VM is up with its persisted identity",
+			operationID: recentOperation,
+			vmi:         masqueradeVMI,
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						V4IPAddress: "10.0.0.1",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			wantCompleted:  true,
+			wantFailed:     false,
+			wantNCompleted: 1,
+			wantErr:        false,
+		},
+		{
+			name:           "VMI not created yet",
+			operationID:    recentOperation,
+			vmi:            nil,
+			wantCompleted:  false,
+			wantFailed:     false,
+			wantNCompleted: 0,
+			wantErr:        false,
+		},
+		{
+			name:           "IP not allocated yet",
+			operationID:    recentOperation,
+			vmi:            runningVMI,
+			wantCompleted:  false,
+			wantFailed:     false,
+			wantNCompleted: 0,
+			wantErr:        false,
+		},
+		{
+			name:               "Allocated IP drifted past the timeout",
+			operationID:        expiredOperation,
+			restoreAnnotations: map[string]string{waitForNetworkAnnotation: "true"},
+			vmi:                runningVMI,
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						V4IPAddress: "10.0.0.2",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			wantCompleted:  true,
+			wantFailed:     true,
+			wantNCompleted: 0,
+			wantErr:        false,
+		},
+		{
+			name:        "Verified identity stored in a ConfigMap",
+			operationID: recentOperation,
+			restoreAnnotations: map[string]string{
+				waitForNetworkAnnotation:        "true",
+				verificationConfigMapAnnotation: "test-report",
+			},
+			vmi: runningVMI,
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						V4IPAddress: "10.0.0.1",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			wantCompleted:  true,
+			wantFailed:     false,
+			wantNCompleted: 1,
+			wantReport:     u.VerificationMatched,
+			wantErr:        false,
+		},
+		{
+			name:        "Drift reported without failing when only verifying",
+			operationID: expiredOperation,
+			restoreAnnotations: map[string]string{
+				verificationConfigMapAnnotation: "test-report",
+			},
+			vmi: runningVMI,
+			existingIPs: []*v1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: v1.IPSpec{
+						V4IPAddress: "10.0.0.2",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			wantCompleted:  true,
+			wantFailed:     false,
+			wantNCompleted: 0,
+			wantReport:     u.VerificationIPDrifted,
+			wantErr:        false,
+		},
+		{
+			name:        "Invalid operation ID",
+			operationID: "test-ns/test-vm",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up fake Kube-OVN client
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			u.GetKubeOvnClient = func() (u.KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			fakeKubeClient := kubefake.NewSimpleClientset()
+			u.GetKubeClient = func() (kubernetes.Interface, error) {
+				return fakeKubeClient, nil
+			}
+
+			u.GetVM = func(namespace, name string) (*kvcore.VirtualMachine, error) {
+				return vm, nil
+			}
+			u.GetVMI = func(namespace, name string) (*kvcore.VirtualMachineInstance, error) {
+				if tt.vmi == nil {
+					return nil, apierrors.NewNotFound(schema.GroupResource{Group: "kubevirt.io", Resource: "virtualmachineinstances"}, name)
+				}
+				return tt.vmi, nil
+			}
+
+			restore := &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-restore",
+					Namespace:   "velero",
+					Annotations: tt.restoreAnnotations,
+				},
+			}
+
+			got, err := action.Progress(tt.operationID, restore)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Progress() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.Completed != tt.wantCompleted {
+				t.Errorf("Progress() completed = %v, want %v", got.Completed, tt.wantCompleted)
+			}
+			if (got.Err != "") != tt.wantFailed {
+				t.Errorf("Progress() err = %s, wantFailed %v", got.Err, tt.wantFailed)
+			}
+			if got.NCompleted != tt.wantNCompleted || got.NTotal != 1 {
+				t.Errorf("Progress() got %d/%d interfaces, want %d/1", got.NCompleted, got.NTotal, tt.wantNCompleted)
+			}
+
+			configMap, err := fakeKubeClient.CoreV1().ConfigMaps("velero").Get(context.Background(), "test-report", metav1.GetOptions{})
+			if tt.wantReport == "" {
+				if err == nil {
+					t.Errorf("Progress() unexpected verification report %v", configMap.Data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Progress() expected a verification report: %v", err)
+			}
+			var report u.VerificationReport
+			if err := json.Unmarshal([]byte(configMap.Data["test-ns.test-vm"]), &report); err != nil {
+				t.Fatalf("failed to decode verification report: %v", err)
+			}
+			if len(report.Interfaces) != 1 || report.Interfaces[0].Status != tt.wantReport {
+				t.Errorf("Progress() got verification report %+v, want status %s", report, tt.wantReport)
+			}
+		})
+	}
+}
+
+func TestShouldWaitForNetwork(t *testing.T) {
+	always := kvcore.RunStrategyAlways
+	halted := kvcore.RunStrategyHalted
+
+	tests := []struct {
+		name        string
+		runStrategy *kvcore.VirtualMachineRunStrategy
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name:        "running VM with a persisted identity",
+			runStrategy: &always,
+			annotations: map[string]string{"ovn.kubernetes.io/ip_address": "10.0.0.1"},
+			want:        true,
+		},
+		{
+			name:        "halted VM",
+			runStrategy: &halted,
+			annotations: map[string]string{"ovn.kubernetes.io/ip_address": "10.0.0.1"},
+			want:        false,
+		},
+		{
+			name:        "running VM without persisted identity",
+			runStrategy: &always,
+			annotations: map[string]string{"existing.annotation": "preserved"},
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := &kvcore.VirtualMachine{
+				Spec: kvcore.VirtualMachineSpec{
+					RunStrategy: tt.runStrategy,
+					Template: &kvcore.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: tt.annotations,
+						},
+					},
+				},
+			}
+
+			if got := shouldWaitForNetwork(vm); got != tt.want {
+				t.Errorf("shouldWaitForNetwork() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOperationID(t *testing.T) {
+	started := time.Unix(1700000000, 0)
+
+	namespace, name, gotStarted, err := parseOperationID(newOperationID("test-ns", "test-vm", started))
+	if err != nil {
+		t.Fatalf("parseOperationID() unexpected error = %v", err)
+	}
+	if namespace != "test-ns" || name != "test-vm" || !gotStarted.Equal(started) {
+		t.Errorf("parseOperationID() got %s/%s started at %v, want test-ns/test-vm started at %v", namespace, name, gotStarted, started)
+	}
+
+	for _, operationID := range []string{"", "test-ns/test-vm", "test-ns/test-vm/now", "a/b/c/1"} {
+		if _, _, _, err := parseOperationID(operationID); err == nil {
+			t.Errorf("parseOperationID() expected an error for %s", operationID)
+		}
+	}
+}
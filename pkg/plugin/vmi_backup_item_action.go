@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+// VMIBackupItemAction persists the network identity of the VMIs created without a VM.
+// The identity of the VMIs owned by a VM is already persisted in the template of the VM by VMBackupItemAction.
+type VMIBackupItemAction struct {
+	log logrus.FieldLogger
+}
+
+func NewVMIBackupItemAction(logger logrus.FieldLogger) *VMIBackupItemAction {
+	return &VMIBackupItemAction{
+		log: logger,
+	}
+}
+
+func (v *VMIBackupItemAction) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: []string{"virtualmachineinstances.kubevirt.io"},
+	}, nil
+}
+
+func (v *VMIBackupItemAction) Execute(item runtime.Unstructured, backup *velerov1api.Backup) (runtime.Unstructured, []velero.ResourceIdentifier, error) {
+	v.log.Info("Executing VMIBackupItemAction")
+
+	// No backup, errors out
+	if backup == nil {
+		return nil, nil, fmt.Errorf("backup object is nil")
+	}
+
+	// Read the settings of the backup
+	config, err := newBackupConfig(backup)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	// Retrieve the VMI we are trying to backup
+	vmi := new(kvcore.VirtualMachineInstance)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.UnstructuredContent(), vmi); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	// The VM owning the VMI recreates it on restore, from its own template
+	if isOwnedByVM(vmi) {
+		v.log.Infof("Skipping VMI %s/%s owned by a VM", vmi.Namespace, vmi.Name)
+		return item, nil, nil
+	}
+
+	// Kube-OVN releases the addresses of a VMI once it is stopped, there is nothing left to persist
+	if vmi.IsFinal() {
+		v.log.Infof("Skipping VMI %s/%s in final phase %s", vmi.Namespace, vmi.Name, vmi.Status.Phase)
+		return item, nil, nil
+	}
+
+	// Retrieve the identity of the VMI, to persist its MAC/IPs and back up the subnets they are allocated from
+	netInfos, skipped, err := u.GetNetInfoForVMI(vmi, config.trustPersisted())
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	logNetInfoSources(v.log, netInfos, vmi.Namespace, vmi.Name)
+	logSkippedNetworks(v.log, skipped, vmi.Namespace, vmi.Name)
+
+	// The virt-launcher pod carries the effective settings of the interfaces, like their security groups
+	podAnnotations, err := u.GetLauncherPodAnnotations(vmi.Name, vmi.Namespace)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	annotations := u.NetInfosToAnnotations(config.netInfosToPersist(netInfos), podAnnotations)
+
+	// KubeVirt copies the annotations of the VMI to its virt-launcher pod, where Kube-OVN reads them
+	if vmi.ObjectMeta.Annotations == nil {
+		vmi.ObjectMeta.Annotations = make(map[string]string)
+	}
+	for k, v := range annotations {
+		vmi.ObjectMeta.Annotations[k] = v
+	}
+
+	// Back up the network objects the VMI depends on along with it
+	additionalItems, err := backupAdditionalItems(netInfos, vmi.Spec.Networks, vmi.Name, vmi.Namespace, vmi.ObjectMeta.Annotations)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	vmiUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vmi)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	return &unstructured.Unstructured{Object: vmiUnstructured}, additionalItems, nil
+}
+
+// isOwnedByVM checks whether a VMI is controlled by a KubeVirt VM
+func isOwnedByVM(vmi *kvcore.VirtualMachineInstance) bool {
+	owner := metav1.GetControllerOf(vmi)
+	if owner == nil {
+		return false
+	}
+
+	// Compare the group and kind only, older objects may reference another version of the KubeVirt API
+	return schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind).GroupKind() == kvcore.VirtualMachineGroupVersionKind.GroupKind()
+}
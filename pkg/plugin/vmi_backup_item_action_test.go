@@ -0,0 +1,236 @@
+package plugin
+
+import (
+	"context"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
+	"reflect"
+	"testing"
+
+	"github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+func TestVMIExecute(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider
+	originalGetNetworkClient := u.GetNetworkClient
+	defer func() { u.GetNetworkClient = originalGetNetworkClient }()
+	u.GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadfake.NewSimpleClientset(), nil
+	}
+
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := u.GetKubeOvnClient
+	defer func() { u.GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	// Mock GetKubeClient
+	originalGetKubeClient := u.GetKubeClient
+	defer func() { u.GetKubeClient = originalGetKubeClient }()
+
+	// The pod of a previous run of the VMI is ignored
+	fakeKubeClient := kubefake.NewSimpleClientset(
+		newLauncherPod("test-vmi", "test-ns", corev1.PodFailed, map[string]string{"ovn.kubernetes.io/security_groups": "sg-stale"}),
+		newLauncherPod("test-vmi", "test-ns", corev1.PodRunning, map[string]string{"ovn.kubernetes.io/security_groups": "sg-web"}),
+	)
+	u.GetKubeClient = func() (kubernetes.Interface, error) {
+		return fakeKubeClient, nil
+	}
+
+	logger := logrus.New()
+	action := NewVMIBackupItemAction(logger)
+
+	isController := true
+	backup := &velerov1api.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-backup",
+		},
+	}
+	existingIPs := []*v1.IP{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-vmi.test-ns",
+			},
+			Spec: v1.IPSpec{
+				Subnet:      "ovn-default",
+				V4IPAddress: "10.0.0.1",
+				MacAddress:  "00:00:00:00:00:01",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-vmi.test-ns.nad1.test-ns.ovn",
+			},
+			Spec: v1.IPSpec{
+				V4IPAddress: "10.0.1.1",
+				MacAddress:  "00:00:00:00:00:11",
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		vmi             *kvcore.VirtualMachineInstance
+		backup          *velerov1api.Backup
+		wantAnnotations map[string]string
+		wantAdditional  []velero.ResourceIdentifier
+		wantErr         bool
+	}{
+		{
+			name: "Standalone VMI - annotations added to the VMI",
+			vmi: &kvcore.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vmi",
+					Namespace: "test-ns",
+					Annotations: map[string]string{
+						"existing.annotation": "preserved",
+					},
+				},
+				Spec: kvcore.VirtualMachineInstanceSpec{
+					Networks: []kvcore.Network{
+						{
+							Name: "secondary",
+							NetworkSource: kvcore.NetworkSource{
+								Multus: &kvcore.MultusNetwork{
+									NetworkName: "test-ns/nad1",
+								},
+							},
+						},
+					},
+				},
+				Status: kvcore.VirtualMachineInstanceStatus{
+					Phase: kvcore.Running,
+				},
+			},
+			backup: backup,
+			wantAnnotations: map[string]string{
+				"existing.annotation":                        "preserved",
+				"ovn.kubernetes.io/ip_address":               "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":              "00:00:00:00:00:01",
+				"ovn.kubernetes.io/logical_switch":           "ovn-default",
+				"ovn.kubernetes.io/security_groups":          "sg-web",
+				"nad1.test-ns.ovn.kubernetes.io/ip_address":  "10.0.1.1",
+				"nad1.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:11",
+			},
+			wantAdditional: []velero.ResourceIdentifier{
+				{GroupResource: subnetsResource, Name: "ovn-default"},
+				{GroupResource: nadsResource, Namespace: "test-ns", Name: "nad1"},
+				{GroupResource: securityGroupsResource, Name: "sg-web"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "VMI owned by a VM - left untouched",
+			vmi: &kvcore.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vmi",
+					Namespace: "test-ns",
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "kubevirt.io/v1",
+							Kind:       "VirtualMachine",
+							Name:       "test-vmi",
+							Controller: &isController,
+						},
+					},
+				},
+			},
+			backup:          backup,
+			wantAnnotations: map[string]string{},
+			wantErr:         false,
+		},
+		{
+			name: "Stopped VMI - left untouched",
+			vmi: &kvcore.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "stopped-vmi",
+					Namespace: "test-ns",
+				},
+				Status: kvcore.VirtualMachineInstanceStatus{
+					Phase: kvcore.Succeeded,
+				},
+			},
+			backup:          backup,
+			wantAnnotations: map[string]string{},
+			wantErr:         false,
+		},
+		{
+			name: "Standalone VMI without IP",
+			vmi: &kvcore.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unknown-vmi",
+					Namespace: "test-ns",
+				},
+			},
+			backup:  backup,
+			wantErr: true,
+		},
+		{
+			name:    "Nil backup object",
+			vmi:     &kvcore.VirtualMachineInstance{},
+			backup:  nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up fake Kube-OVN client
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			u.GetKubeOvnClient = func() (u.KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			// Convert VMI to Unstructured
+			vmiUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(tt.vmi)
+			if err != nil {
+				t.Fatalf("failed to convert VMI to unstructured: %v", err)
+			}
+
+			obj := &unstructured.Unstructured{Object: vmiUnstructured}
+
+			got, additionalItems, err := action.Execute(obj, tt.backup)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// Convert back to VMI to check annotations
+			gotVMI := new(kvcore.VirtualMachineInstance)
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(got.UnstructuredContent(), gotVMI)
+			if err != nil {
+				t.Fatalf("failed to convert returned item back to VMI: %v", err)
+			}
+
+			annotations := gotVMI.ObjectMeta.Annotations
+			if len(annotations) != len(tt.wantAnnotations) {
+				t.Errorf("Execute() got annotations %v, want %v", annotations, tt.wantAnnotations)
+			}
+			for k, v := range tt.wantAnnotations {
+				if annotations[k] != v {
+					t.Errorf("Execute() annotation %s = %v, want %v", k, annotations[k], v)
+				}
+			}
+
+			if !reflect.DeepEqual(additionalItems, tt.wantAdditional) {
+				t.Errorf("Execute() got additional items %v, want %v", additionalItems, tt.wantAdditional)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kvcore "kubevirt.io/api/core/v1"
+)
+
+// VMIRestoreItemAction restores the persisted network identity of the VMIs created without a VM, like
+// VMRestoreItemAction does for VMs. The VMIs owned by a VM are recreated by the VM, from its own template.
+type VMIRestoreItemAction struct {
+	log logrus.FieldLogger
+}
+
+func NewVMIRestoreItemAction(logger logrus.FieldLogger) *VMIRestoreItemAction {
+	return &VMIRestoreItemAction{
+		log: logger,
+	}
+}
+
+func (r *VMIRestoreItemAction) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: []string{"virtualmachineinstances.kubevirt.io"},
+	}, nil
+}
+
+func (r *VMIRestoreItemAction) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	r.log.Info("Executing VMIRestoreItemAction")
+
+	// No restore, errors out
+	if input == nil || input.Restore == nil {
+		return nil, fmt.Errorf("restore object is nil")
+	}
+
+	// Read the settings of the restore
+	config, err := newRestoreConfig(input.Restore)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Retrieve the VMI we are trying to restore
+	vmi := new(kvcore.VirtualMachineInstance)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(input.Item.UnstructuredContent(), vmi); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// The identity of the VMIs owned by a VM is restored along with the VM
+	if isOwnedByVM(vmi) {
+		r.log.Infof("Skipping VMI %s/%s owned by a VM", vmi.Namespace, vmi.Name)
+		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
+	}
+
+	// Check the persisted identity of the VMI against the target cluster, or drop it for clones
+	restorer := &identityRestorer{log: r.log}
+	if err := restorer.restore(restoredVMI(vmi), input.Restore, config); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	vmiUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vmi)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return velero.NewRestoreItemActionExecuteOutput(&unstructured.Unstructured{Object: vmiUnstructured}), nil
+}
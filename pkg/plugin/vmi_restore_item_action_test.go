@@ -0,0 +1,275 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	"github.com/sirupsen/logrus"
+	u "github.com/super-phenix/superphenix-velero-plugin/pkg/util"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kvcore "kubevirt.io/api/core/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
+)
+
+func TestVMIRestoreExecute(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider
+	originalGetNetworkClient := u.GetNetworkClient
+	defer func() { u.GetNetworkClient = originalGetNetworkClient }()
+	u.GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadfake.NewSimpleClientset(), nil
+	}
+
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := u.GetKubeOvnClient
+	defer func() { u.GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	// Mock ListVMIs
+	originalListVMIs := u.ListVMIs
+	defer func() { u.ListVMIs = originalListVMIs }()
+	u.ListVMIs = func() ([]kvcore.VirtualMachineInstance, error) {
+		return nil, nil
+	}
+
+	logger := logrus.New()
+	action := NewVMIRestoreItemAction(logger)
+
+	isController := true
+	subnet := &v1.Subnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "ovn-default",
+		},
+		Spec: v1.SubnetSpec{
+			CIDRBlock: "10.0.0.0/24",
+		},
+	}
+	annotations := map[string]string{
+		"ovn.kubernetes.io/ip_address":                  "10.0.0.1",
+		"ovn.kubernetes.io/mac_address":                 "00:00:00:00:00:01",
+		"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+	}
+	originalIP := &v1.IP{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-vmi.test-ns",
+		},
+		Spec: v1.IPSpec{
+			PodName:     "test-vmi",
+			Namespace:   "test-ns",
+			Subnet:      "ovn-default",
+			V4IPAddress: "10.0.0.1",
+		},
+	}
+
+	tests := []struct {
+		name            string
+		ownerReferences []metav1.OwnerReference
+		existingIPs     []*v1.IP
+		restore         *velerov1api.Restore
+		wantAnnotations map[string]string
+		wantNetworkName string
+		wantMACAddress  string
+		wantReservedIPs []string
+		wantErr         bool
+	}{
+		{
+			name:            "Valid identity is kept",
+			restore:         &velerov1api.Restore{},
+			wantAnnotations: annotations,
+			wantNetworkName: "test-ns/test-nad",
+			wantMACAddress:  "00:00:00:00:00:01",
+			wantErr:         false,
+		},
+		{
+			name: "Clone mode drops the identity",
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{restoreModeKey: "clone"},
+				},
+			},
+			wantAnnotations: map[string]string{},
+			wantNetworkName: "test-ns/test-nad",
+			wantMACAddress:  "",
+			wantErr:         false,
+		},
+		{
+			name: "Namespace mapping rewrites NAD annotations and network names",
+			restore: &velerov1api.Restore{
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "new-ns"},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                 "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                "00:00:00:00:00:01",
+				"test-nad.new-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			wantNetworkName: "new-ns/test-nad",
+			wantMACAddress:  "00:00:00:00:00:01",
+			wantErr:         false,
+		},
+		{
+			name: "CIDR mapping translates the identity",
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						cidrMappingAnnotation: `[{"from": "10.0.0.2/32", "to": "10.0.0.3/32", "network": "test-ns/test-nad"}]`,
+					},
+				},
+			},
+			wantAnnotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                 "00:00:00:00:00:01",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.0.0.3",
+			},
+			wantNetworkName: "test-ns/test-nad",
+			wantMACAddress:  "00:00:00:00:00:01",
+			wantErr:         false,
+		},
+		{
+			name:        "IP conflict with the original VMI fails a restore into a mapped namespace",
+			existingIPs: []*v1.IP{originalIP},
+			restore: &velerov1api.Restore{
+				Spec: velerov1api.RestoreSpec{
+					NamespaceMapping: map[string]string{"test-ns": "new-ns"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Persisted IP addresses are reserved",
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-restore",
+					Annotations: map[string]string{reserveIPsAnnotation: "true"},
+				},
+			},
+			wantAnnotations: annotations,
+			wantNetworkName: "test-ns/test-nad",
+			wantMACAddress:  "00:00:00:00:00:01",
+			wantReservedIPs: []string{"test-vmi.test-ns", "test-vmi.test-ns.test-nad.test-ns.ovn"},
+			wantErr:         false,
+		},
+		{
+			name: "VMI owned by a VM is left unchanged",
+			ownerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "kubevirt.io/v1",
+					Kind:       "VirtualMachine",
+					Name:       "test-vmi",
+					Controller: &isController,
+				},
+			},
+			restore: &velerov1api.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{restoreModeKey: "clone"},
+				},
+			},
+			wantAnnotations: annotations,
+			wantNetworkName: "test-ns/test-nad",
+			wantMACAddress:  "00:00:00:00:00:01",
+			wantErr:         false,
+		},
+		{
+			name:    "Nil restore object",
+			restore: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up fake Kube-OVN client
+			fakeClient := fake.NewSimpleClientset()
+			_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), subnet, metav1.CreateOptions{})
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			u.GetKubeOvnClient = func() (u.KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			vmiAnnotations := make(map[string]string)
+			for k, v := range annotations {
+				vmiAnnotations[k] = v
+			}
+			vmi := &kvcore.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "test-vmi",
+					Namespace:       "test-ns",
+					Annotations:     vmiAnnotations,
+					OwnerReferences: tt.ownerReferences,
+				},
+				Spec: kvcore.VirtualMachineInstanceSpec{
+					Domain: kvcore.DomainSpec{
+						Devices: kvcore.Devices{
+							Interfaces: []kvcore.Interface{
+								{Name: "default", MacAddress: "00:00:00:00:00:01"},
+							},
+						},
+					},
+					Networks: []kvcore.Network{
+						{Name: "default", NetworkSource: kvcore.NetworkSource{Pod: &kvcore.PodNetwork{}}},
+						{Name: "secondary", NetworkSource: kvcore.NetworkSource{Multus: &kvcore.MultusNetwork{NetworkName: "test-ns/test-nad"}}},
+					},
+				},
+			}
+
+			vmiUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vmi)
+			if err != nil {
+				t.Fatalf("failed to convert VMI to unstructured: %v", err)
+			}
+			obj := &unstructured.Unstructured{Object: vmiUnstructured}
+
+			got, err := action.Execute(&velero.RestoreItemActionExecuteInput{
+				Item:           obj,
+				ItemFromBackup: obj,
+				Restore:        tt.restore,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			gotVMI := new(kvcore.VirtualMachineInstance)
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(got.UpdatedItem.UnstructuredContent(), gotVMI); err != nil {
+				t.Fatalf("failed to convert returned item back to VMI: %v", err)
+			}
+
+			if len(gotVMI.Annotations) != len(tt.wantAnnotations) {
+				t.Errorf("Execute() got annotations %v, want %v", gotVMI.Annotations, tt.wantAnnotations)
+			}
+			for k, v := range tt.wantAnnotations {
+				if gotVMI.Annotations[k] != v {
+					t.Errorf("Execute() expected annotation %s=%s, got %s", k, v, gotVMI.Annotations[k])
+				}
+			}
+
+			if networkName := gotVMI.Spec.Networks[1].Multus.NetworkName; networkName != tt.wantNetworkName {
+				t.Errorf("Execute() expected network to reference %s, got %s", tt.wantNetworkName, networkName)
+			}
+			if mac := gotVMI.Spec.Domain.Devices.Interfaces[0].MacAddress; mac != tt.wantMACAddress {
+				t.Errorf("Execute() expected interface to have MAC address %s, got %s", tt.wantMACAddress, mac)
+			}
+
+			for _, name := range tt.wantReservedIPs {
+				ip, err := fakeClient.KubeovnV1().IPs().Get(context.Background(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Errorf("Execute() expected IP %s to be reserved: %v", name, err)
+					continue
+				}
+				if ip.Labels[reservedByRestoreLabel] != tt.restore.Name {
+					t.Errorf("Execute() expected IP %s to be reserved by %s, got %s", name, tt.restore.Name, ip.Labels[reservedByRestoreLabel])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// CIDRMapping translates the addresses of a CIDR of the source cluster into a CIDR of the target cluster,
+// preserving the offset of every address inside its CIDR.
+type CIDRMapping struct {
+	From *net.IPNet
+	To   *net.IPNet
+}
+
+// NewCIDRMapping creates a CIDRMapping between two CIDRs of the same IP family.
+// The target CIDR must be at least as large as the source CIDR, so that every offset can be preserved.
+func NewCIDRMapping(from, to string) (*CIDRMapping, error) {
+	_, fromNet, err := net.ParseCIDR(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source CIDR %s: %w", from, err)
+	}
+
+	_, toNet, err := net.ParseCIDR(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target CIDR %s: %w", to, err)
+	}
+
+	fromOnes, fromBits := fromNet.Mask.Size()
+	toOnes, toBits := toNet.Mask.Size()
+	if fromBits != toBits {
+		return nil, fmt.Errorf("expected CIDRs %s and %s to be of the same IP family", from, to)
+	}
+	if toOnes > fromOnes {
+		return nil, fmt.Errorf("expected CIDR %s to be at least as large as %s", to, from)
+	}
+
+	return &CIDRMapping{From: fromNet, To: toNet}, nil
+}
+
+// Translate translates an address of the source CIDR into the target CIDR.
+// Returns false if the address doesn't belong to the source CIDR.
+func (m *CIDRMapping) Translate(ip net.IP) (net.IP, bool) {
+	if !m.From.Contains(ip) {
+		return nil, false
+	}
+
+	// Both networks share the IP family, so they share the length of their representation
+	address := ip.To16()
+	if len(m.From.IP) == net.IPv4len {
+		address = ip.To4()
+	}
+
+	translated := make(net.IP, len(address))
+	for i := range address {
+		translated[i] = m.To.IP[i] | (address[i] &^ m.From.Mask[i])
+	}
+
+	return translated, true
+}
+
+// RemapIPs translates every address of a comma-separated IP list, as built by IPToNetInfo, with the first mapping
+// whose source CIDR contains it. Addresses that don't belong to any source CIDR are kept unchanged.
+func RemapIPs(ips string, mappings []CIDRMapping) (string, error) {
+	addresses := strings.Split(ips, ",")
+
+	for i, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return "", fmt.Errorf("invalid IP address %s", address)
+		}
+
+		for _, mapping := range mappings {
+			if translated, ok := mapping.Translate(ip); ok {
+				addresses[i] = translated.String()
+				break
+			}
+		}
+	}
+
+	return strings.Join(addresses, ","), nil
+}
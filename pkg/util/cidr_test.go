@@ -0,0 +1,132 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestNewCIDRMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{
+			name:    "ipv4 CIDRs of the same size",
+			from:    "10.16.0.0/16",
+			to:      "10.116.0.0/16",
+			wantErr: false,
+		},
+		{
+			name:    "larger target CIDR",
+			from:    "10.16.0.0/24",
+			to:      "10.116.0.0/16",
+			wantErr: false,
+		},
+		{
+			name:    "smaller target CIDR",
+			from:    "10.16.0.0/16",
+			to:      "10.116.0.0/24",
+			wantErr: true,
+		},
+		{
+			name:    "ipv6 CIDRs",
+			from:    "fd00:10:16::/64",
+			to:      "fd00:10:116::/64",
+			wantErr: false,
+		},
+		{
+			name:    "different IP families",
+			from:    "10.16.0.0/16",
+			to:      "fd00:10:116::/64",
+			wantErr: true,
+		},
+		{
+			name:    "invalid source CIDR",
+			from:    "10.16.0.0",
+			to:      "10.116.0.0/16",
+			wantErr: true,
+		},
+		{
+			name:    "invalid target CIDR",
+			from:    "10.16.0.0/16",
+			to:      "10.116.0.0/33",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCIDRMapping(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCIDRMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemapIPs(t *testing.T) {
+	var mappings []CIDRMapping
+	for _, cidrs := range [][2]string{
+		{"10.16.0.0/16", "10.116.0.0/16"},
+		{"10.17.1.0/24", "192.168.0.0/16"},
+		{"fd00:10:16::/64", "fd00:10:116::/64"},
+	} {
+		mapping, err := NewCIDRMapping(cidrs[0], cidrs[1])
+		if err != nil {
+			t.Fatalf("NewCIDRMapping() unexpected error = %v", err)
+		}
+		mappings = append(mappings, *mapping)
+	}
+
+	tests := []struct {
+		name    string
+		ips     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "ipv4 address",
+			ips:     "10.16.3.12",
+			want:    "10.116.3.12",
+			wantErr: false,
+		},
+		{
+			name:    "ipv4 address into a larger CIDR",
+			ips:     "10.17.1.12",
+			want:    "192.168.0.12",
+			wantErr: false,
+		},
+		{
+			name:    "dual stack addresses",
+			ips:     "10.16.3.12,fd00:10:16::3:12",
+			want:    "10.116.3.12,fd00:10:116::3:12",
+			wantErr: false,
+		},
+		{
+			name:    "unmapped address",
+			ips:     "10.18.3.12",
+			want:    "10.18.3.12",
+			wantErr: false,
+		},
+		{
+			name:    "invalid address",
+			ips:     "10.16.3.300",
+			want:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemapIPs(tt.ips, mappings)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RemapIPs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("RemapIPs() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,12 +3,16 @@ package util
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 
 	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
 	clientset "github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned"
 	kubeovnclient "github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/typed/kubeovn/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -16,9 +20,33 @@ import (
 const (
 	defaultNetworkAnnotation = "ovn.kubernetes.io"
 	defaultNetworkPattern    = "%s.%s"
-	nadNetworkPattern        = "%s.%s.%s.%s.ovn"
+	providerNetworkPattern   = "%s.%s.%s"
+
+	macAddressAnnotation     = "mac_address"
+	ipAddressAnnotation      = "ip_address"
+	logicalSwitchAnnotation  = "logical_switch"
+	securityGroupsAnnotation = "security_groups"
+	ingressRateAnnotation    = "ingress_rate"
+	egressRateAnnotation     = "egress_rate"
+
+	// VirtualMachinePodType is the pod type of the Kube-OVN IPs allocated or reserved for a KubeVirt VM
+	VirtualMachinePodType = "VirtualMachine"
 )
 
+// allocationAnnotations are the settings Kube-OVN writes on a pod when it allocates its addresses
+var allocationAnnotations = map[string]bool{
+	"allocated":             true,
+	"routed":                true,
+	macAddressAnnotation:    true,
+	ipAddressAnnotation:     true,
+	"cidr":                  true,
+	"gateway":               true,
+	logicalSwitchAnnotation: true,
+	"logical_router":        true,
+	"pod_nic_type":          true,
+	"vpc_cidrs":             true,
+}
+
 type KubeOvnClient interface {
 	KubeovnV1() kubeovnclient.KubeovnV1Interface
 }
@@ -33,11 +61,32 @@ var GetKubeOvnClient = func() (KubeOvnClient, error) {
 	return clientset.NewForConfig(cfg)
 }
 
+// NetInfoSource tells where the network information of an interface was read from
+type NetInfoSource string
+
+const (
+	// NetInfoSourceIP is the Kube-OVN IP custom resource of the interface
+	NetInfoSourceIP NetInfoSource = "IP"
+	// NetInfoSourceVMIStatus is the interface reported in the status of the VMI
+	NetInfoSourceVMIStatus NetInfoSource = "VMIStatus"
+	// NetInfoSourceAnnotations are the Kube-OVN annotations persisted by a previous backup or restore
+	NetInfoSourceAnnotations NetInfoSource = "Annotations"
+)
+
 // NetInfo represents the network information for a VM interface
 type NetInfo struct {
 	NADAnnotation string
 	MAC           string
 	IPs           string
+	// Subnet is the Kube-OVN subnet, or logical switch, the addresses of the interface are allocated from
+	Subnet string
+	// SecurityGroups is the comma-separated list of the Kube-OVN security groups the interface is a member of
+	SecurityGroups string
+	// IngressRate and EgressRate are the bandwidth limits of the interface, in Mbit/s
+	IngressRate string
+	EgressRate  string
+	// Source tells where the MAC and IP addresses of the interface were read from
+	Source NetInfoSource
 }
 
 // GetIPForVM retrieves the IP custom resource associated with a VM's network annotation, name, and namespace.
@@ -104,43 +153,190 @@ func getIPNameForDefaultNetwork(vmName, vmNamespace string) (string, error) {
 	return fmt.Sprintf(defaultNetworkPattern, vmName, vmNamespace), nil
 }
 
-// getIPNameForNADNetwork generates the IP CR name for a VM attached through a NetworkAttachmentDefinition.
+// getIPNameForNADNetwork generates the IP CR name for a VM attached through a NetworkAttachmentDefinition, which may
+// live in another namespace than the VM. Kube-OVN names the IP after the provider of the NAD, which prefixes the NAD
+// annotation: [NAD].[NS].ovn by default, or the provider configured in the NAD.
 // Returns the formatted IP name or an error if either VM name or namespace is empty.
 func getIPNameForNADNetwork(nadAnnotation, vmName, vmNamespace string) (string, error) {
 	if vmName == "" || vmNamespace == "" {
 		return "", fmt.Errorf("expected a VM name/namespace, got '%s' and '%s'", vmName, vmNamespace)
 	}
 
-	// We remove the useless prefix at the end of the annotation to extract only the information we need
-	annotation, found := strings.CutSuffix(nadAnnotation, "."+defaultNetworkAnnotation)
-	if !found {
-		return "", fmt.Errorf("expected NAD annotation to end with .ovn.kubernetes.io, got %s", annotation)
+	provider, err := providerOfNadAnnotation(nadAnnotation)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(providerNetworkPattern, vmName, vmNamespace, provider), nil
+}
+
+// providerOfNadAnnotation extracts the Kube-OVN provider prefixing a NAD annotation
+func providerOfNadAnnotation(nadAnnotation string) (string, error) {
+	provider, found := strings.CutSuffix(nadAnnotation, annotationDomain)
+	if !found || !strings.HasSuffix(provider, kubeOvnProviderSuffix) || strings.TrimSuffix(provider, kubeOvnProviderSuffix) == "" {
+		return "", fmt.Errorf("expected NAD annotation to have pattern [PROVIDER].ovn.kubernetes.io, got %s", nadAnnotation)
+	}
+
+	// Kube-OVN providers are made of DNS labels, an empty one means the annotation is malformed
+	for _, label := range strings.Split(provider, ".") {
+		if label == "" {
+			return "", fmt.Errorf("expected NAD annotation to have pattern [PROVIDER].ovn.kubernetes.io, got %s", nadAnnotation)
+		}
 	}
 
-	// We expect to arrive here with an annotation of pattern [NAD].[NS]
-	// We need to extract the name of NAD and namespace.
-	split := strings.Split(annotation, ".")
-	if len(split) != 2 {
-		return "", fmt.Errorf("expected NAD annotation to have pattern [NAD].[NS], got %s", annotation)
+	return provider, nil
+}
+
+// parseNetworkName extracts the name and namespace of the NAD referenced by a Kubevirt NetworkName.
+// Like KubeVirt, a bare [NAD] name resolves in the namespace of the VM, which may be left empty if it isn't known.
+func parseNetworkName(networkName, vmNamespace string) (string, string, error) {
+	split := strings.Split(networkName, "/")
+	switch {
+	case len(split) == 1 && split[0] != "" && vmNamespace != "":
+		return split[0], vmNamespace, nil
+	case len(split) == 1 && split[0] != "":
+		return "", "", fmt.Errorf("expected network name to have format [NS]/[NAD], got %s", networkName)
+	case len(split) != 2 || split[0] == "" || split[1] == "":
+		return "", "", fmt.Errorf("expected network name to have format [NS]/[NAD] or [NAD], got %s", networkName)
 	}
 
-	// NAD and VM must be in the same namespace, otherwise something is wrong
-	nadName, nadNamespace := split[0], split[1]
-	if vmNamespace != nadNamespace {
-		return "", fmt.Errorf("expected NAD to be in the same namespace as the VM, got %s for NAD and %s for VM", nadNamespace, vmNamespace)
+	return split[1], split[0], nil
+}
+
+// NetworkNameToNadAnnotation translates a Kubevirt NetworkName into a NAD annotation.
+// A bare [NAD] name resolves in the namespace of the VM.
+func NetworkNameToNadAnnotation(networkName, vmNamespace string) (string, error) {
+	nadName, nadNamespace, err := parseNetworkName(networkName, vmNamespace)
+	if err != nil {
+		return "", err
 	}
 
-	return fmt.Sprintf(nadNetworkPattern, vmName, vmNamespace, nadName, nadNamespace), nil
+	return fmt.Sprintf("%s.%s.%s", nadName, nadNamespace, defaultNetworkAnnotation), nil
 }
 
-// NetworkNameToNadAnnotation translates a Kubevirt NetworkName into a NAD annotation
-func NetworkNameToNadAnnotation(networkName string) (string, error) {
+// ValidateNetworkName checks that a Kubevirt NetworkName has the format [NS]/[NAD] or [NAD]
+func ValidateNetworkName(networkName string) error {
 	split := strings.Split(networkName, "/")
-	if len(split) != 2 {
-		return "", fmt.Errorf("expected network name to have format [NS]/[NAD], got %s", networkName)
+	if len(split) > 2 || slices.Contains(split, "") {
+		return fmt.Errorf("expected network name to have format [NS]/[NAD] or [NAD], got %s", networkName)
+	}
+
+	return nil
+}
+
+// SameNetwork reports whether two Kubevirt NetworkNames reference the same NAD, bare [NAD] names resolving in the
+// namespace of the VM. Malformed names don't reference any NAD.
+func SameNetwork(networkName, otherNetworkName, vmNamespace string) bool {
+	nadName, nadNamespace, err := parseNetworkName(networkName, vmNamespace)
+	if err != nil {
+		return false
+	}
+	otherNadName, otherNadNamespace, err := parseNetworkName(otherNetworkName, vmNamespace)
+	if err != nil {
+		return false
+	}
+
+	return nadName == otherNadName && nadNamespace == otherNadNamespace
+}
+
+// RemapNadAnnotation rewrites the namespace of the NAD referenced by a NAD annotation according to a namespace mapping.
+// The default network annotation, NADs in namespaces absent from the mapping and custom providers are returned unchanged.
+func RemapNadAnnotation(nadAnnotation string, namespaceMapping map[string]string) (string, error) {
+	if nadAnnotation == defaultNetworkAnnotation {
+		return nadAnnotation, nil
+	}
+
+	provider, err := providerOfNadAnnotation(nadAnnotation)
+	if err != nil {
+		return "", err
+	}
+
+	remapped, err := remapProvider(provider, namespaceMapping)
+	if err != nil {
+		return "", err
 	}
 
-	return fmt.Sprintf("%s.%s.%s", split[1], split[0], defaultNetworkAnnotation), nil
+	return remapped + annotationDomain, nil
+}
+
+// remapProvider rewrites the namespace of a Kube-OVN provider according to a namespace mapping.
+// Only the default [NAD].[NS].ovn providers embed a namespace: custom providers, even when they look like the
+// default one, are chosen by the NAD and returned unchanged.
+func remapProvider(provider string, namespaceMapping map[string]string) (string, error) {
+	split := strings.Split(provider, ".")
+	if len(split) != 3 || "."+split[2] != kubeOvnProviderSuffix {
+		return provider, nil
+	}
+
+	target, ok := namespaceMapping[split[1]]
+	if !ok {
+		return provider, nil
+	}
+
+	isDefault, err := isDefaultProvider(split[0], split[1])
+	if err != nil {
+		return "", err
+	}
+	if !isDefault {
+		return provider, nil
+	}
+
+	return fmt.Sprintf("%s.%s%s", split[0], target, kubeOvnProviderSuffix), nil
+}
+
+// RemapNetworkName rewrites the namespace of a Kubevirt NetworkName according to a namespace mapping.
+// NADs in namespaces absent from the mapping are returned unchanged, as are bare [NAD] names,
+// which follow the VM into its target namespace.
+func RemapNetworkName(networkName string, namespaceMapping map[string]string) (string, error) {
+	if !strings.Contains(networkName, "/") {
+		return networkName, nil
+	}
+
+	nadName, nadNamespace, err := parseNetworkName(networkName, "")
+	if err != nil {
+		return "", err
+	}
+
+	target, ok := namespaceMapping[nadNamespace]
+	if !ok {
+		return networkName, nil
+	}
+
+	return fmt.Sprintf("%s/%s", target, nadName), nil
+}
+
+// RemapNadAnnotations rewrites the keys of the Kube-OVN annotations of every NAD according to a namespace mapping.
+// The keys are rewritten in a single pass, so that swapping two namespaces doesn't mix up their annotations.
+// Annotations that don't reference a NAD are kept unchanged.
+func RemapNadAnnotations(annotations map[string]string, namespaceMapping map[string]string) (map[string]string, error) {
+	remapped := make(map[string]string, len(annotations))
+	// Every setting of a NAD shares its prefix, the NAD only needs to be inspected once
+	targets := make(map[string]string)
+
+	for key, value := range annotations {
+		nadAnnotation, setting, found := strings.Cut(key, "/")
+		if found && nadAnnotation != defaultNetworkAnnotation && strings.HasSuffix(nadAnnotation, defaultNetworkAnnotation) {
+			target, ok := targets[nadAnnotation]
+			if !ok {
+				provider, err := providerOfNadAnnotation(nadAnnotation)
+				if err != nil {
+					remapped[key] = value
+					continue
+				}
+
+				if target, err = remapProvider(provider, namespaceMapping); err != nil {
+					return nil, err
+				}
+				target += annotationDomain
+				targets[nadAnnotation] = target
+			}
+			key = fmt.Sprintf("%s/%s", target, setting)
+		}
+
+		remapped[key] = value
+	}
+
+	return remapped, nil
 }
 
 // IPToNetInfo translates a Kube-OVN IP CR into a NetInfo
@@ -157,13 +353,418 @@ func IPToNetInfo(nadAnnotation string, ip kubeovnv1.IP) *NetInfo {
 		NADAnnotation: nadAnnotation,
 		MAC:           ip.Spec.MacAddress,
 		IPs:           strings.Join(ips, ","),
+		Subnet:        ip.Spec.Subnet,
+		Source:        NetInfoSourceIP,
 	}
 }
 
 // ToAnnotations translates a NetInfo into the corresponding Kube-OVN annotations
 func (n *NetInfo) ToAnnotations() map[string]string {
-	return map[string]string{
-		fmt.Sprintf("%s/%s", n.NADAnnotation, "mac_address"): n.MAC,
-		fmt.Sprintf("%s/%s", n.NADAnnotation, "ip_address"):  n.IPs,
+	// An address that isn't known isn't persisted, Kube-OVN allocates a new one on restore
+	annotations := make(map[string]string)
+	if n.MAC != "" {
+		annotations[n.MACAnnotation()] = n.MAC
+	}
+	if n.IPs != "" {
+		annotations[n.IPAnnotation()] = n.IPs
+	}
+	if n.Subnet != "" {
+		annotations[n.LogicalSwitchAnnotation()] = n.Subnet
+	}
+	if n.SecurityGroups != "" {
+		annotations[n.SecurityGroupsAnnotation()] = n.SecurityGroups
+	}
+	if n.IngressRate != "" {
+		annotations[n.settingAnnotation(ingressRateAnnotation)] = n.IngressRate
+	}
+	if n.EgressRate != "" {
+		annotations[n.settingAnnotation(egressRateAnnotation)] = n.EgressRate
+	}
+
+	return annotations
+}
+
+// DeleteAnnotations removes from a set of annotations the MAC and IP annotations of the NetInfo.
+// The other settings of the interface, like its logical switch, security groups and bandwidth limits, are kept so
+// that it remains on the same subnet, protected and within its limits.
+func (n *NetInfo) DeleteAnnotations(annotations map[string]string) {
+	delete(annotations, n.MACAnnotation())
+	delete(annotations, n.IPAnnotation())
+}
+
+// ReadPodSettings fills the settings of the interface Kube-OVN reads from the annotations of a pod,
+// like its security groups and bandwidth limits. Settings absent from the annotations are left unchanged.
+func (n *NetInfo) ReadPodSettings(podAnnotations map[string]string) {
+	if securityGroups := podAnnotations[n.SecurityGroupsAnnotation()]; securityGroups != "" {
+		n.SecurityGroups = securityGroups
+	}
+	if ingressRate := podAnnotations[n.settingAnnotation(ingressRateAnnotation)]; ingressRate != "" {
+		n.IngressRate = ingressRate
+	}
+	if egressRate := podAnnotations[n.settingAnnotation(egressRateAnnotation)]; egressRate != "" {
+		n.EgressRate = egressRate
 	}
 }
+
+// MACAnnotation returns the key of the annotation carrying the MAC address of the interface
+func (n *NetInfo) MACAnnotation() string {
+	return fmt.Sprintf("%s/%s", n.NADAnnotation, macAddressAnnotation)
+}
+
+// IPAnnotation returns the key of the annotation carrying the IP address(es) of the interface
+func (n *NetInfo) IPAnnotation() string {
+	return fmt.Sprintf("%s/%s", n.NADAnnotation, ipAddressAnnotation)
+}
+
+// LogicalSwitchAnnotation returns the key of the annotation carrying the logical switch of the interface
+func (n *NetInfo) LogicalSwitchAnnotation() string {
+	return n.settingAnnotation(logicalSwitchAnnotation)
+}
+
+// SecurityGroupsAnnotation returns the key of the annotation carrying the security groups of the interface
+func (n *NetInfo) SecurityGroupsAnnotation() string {
+	return n.settingAnnotation(securityGroupsAnnotation)
+}
+
+// settingAnnotation returns the key of the annotation carrying a setting of the interface
+func (n *NetInfo) settingAnnotation(setting string) string {
+	return fmt.Sprintf("%s/%s", n.NADAnnotation, setting)
+}
+
+// ValidateMAC checks that the MAC address of the NetInfo is a valid unicast MAC address
+func (n *NetInfo) ValidateMAC() error {
+	mac, err := net.ParseMAC(n.MAC)
+	if err != nil {
+		return fmt.Errorf("invalid MAC address %s for %s: %w", n.MAC, n.NADAnnotation, err)
+	}
+
+	// Kube-OVN refuses multicast MAC addresses on logical switch ports
+	if mac[0]&0x01 != 0 {
+		return fmt.Errorf("invalid MAC address %s for %s: multicast addresses cannot be assigned to an interface", n.MAC, n.NADAnnotation)
+	}
+
+	return nil
+}
+
+// ValidateIPs checks that the NetInfo contains one or two valid IP addresses, as built by IPToNetInfo
+func (n *NetInfo) ValidateIPs() error {
+	ips := strings.Split(n.IPs, ",")
+	if len(ips) > 2 {
+		return fmt.Errorf("invalid IP addresses %s for %s: expected at most one IPv4 and one IPv6", n.IPs, n.NADAnnotation)
+	}
+
+	ipv4 := 0
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return fmt.Errorf("invalid IP address %s for %s", ip, n.NADAnnotation)
+		}
+		if parsed.To4() != nil {
+			ipv4++
+		}
+	}
+
+	// Kube-OVN pins at most one address of each family on an interface
+	if len(ips) == 2 && ipv4 != 1 {
+		return fmt.Errorf("invalid IP addresses %s for %s: expected at most one IPv4 and one IPv6", n.IPs, n.NADAnnotation)
+	}
+
+	return nil
+}
+
+// MACMatches checks whether another NetInfo carries the same MAC address, regardless of its notation
+func (n *NetInfo) MACMatches(other *NetInfo) bool {
+	mac, err := net.ParseMAC(n.MAC)
+	if err != nil {
+		return n.MAC == other.MAC
+	}
+
+	otherMAC, err := net.ParseMAC(other.MAC)
+	return err == nil && mac.String() == otherMAC.String()
+}
+
+// IPsMatch checks whether another NetInfo carries every IP address of the NetInfo.
+// The other NetInfo may carry more addresses, like the IPv6 link-local addresses reported by a VMI.
+func (n *NetInfo) IPsMatch(other *NetInfo) bool {
+	addresses := make(map[string]bool)
+	for _, address := range strings.Split(other.IPs, ",") {
+		if ip := net.ParseIP(address); ip != nil {
+			addresses[ip.String()] = true
+		}
+	}
+
+	for _, address := range strings.Split(n.IPs, ",") {
+		ip := net.ParseIP(address)
+		if ip == nil || !addresses[ip.String()] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NetInfosFromAnnotations parses the MAC and IP annotations produced by ToAnnotations back into NetInfos, along with
+// their logical switch. Annotations unrelated to Kube-OVN are ignored. The NetInfos are sorted by NAD annotation.
+func NetInfosFromAnnotations(annotations map[string]string) []NetInfo {
+	netInfos := make(map[string]*NetInfo)
+	logicalSwitches := make(map[string]string)
+
+	for key, value := range annotations {
+		nadAnnotation, setting, found := strings.Cut(key, "/")
+		if !found || !strings.HasSuffix(nadAnnotation, defaultNetworkAnnotation) {
+			continue
+		}
+		if setting == logicalSwitchAnnotation {
+			logicalSwitches[nadAnnotation] = value
+			continue
+		}
+		if setting != macAddressAnnotation && setting != ipAddressAnnotation {
+			continue
+		}
+
+		netInfo, ok := netInfos[nadAnnotation]
+		if !ok {
+			netInfo = &NetInfo{NADAnnotation: nadAnnotation, Source: NetInfoSourceAnnotations}
+			netInfos[nadAnnotation] = netInfo
+		}
+
+		if setting == macAddressAnnotation {
+			netInfo.MAC = value
+		} else {
+			netInfo.IPs = value
+		}
+	}
+
+	// A logical switch alone doesn't make an identity, it is only attached to the interfaces that have one
+	result := make([]NetInfo, 0, len(netInfos))
+	for nadAnnotation, netInfo := range netInfos {
+		netInfo.Subnet = logicalSwitches[nadAnnotation]
+		result = append(result, *netInfo)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NADAnnotation < result[j].NADAnnotation
+	})
+
+	return result
+}
+
+// SecurityGroupsOfAnnotations returns the sorted names of the Kube-OVN security groups referenced by a set of
+// annotations, for the default network as well as for every NAD
+func SecurityGroupsOfAnnotations(annotations map[string]string) []string {
+	securityGroups := make(map[string]bool)
+	for key, value := range annotations {
+		nadAnnotation, setting, found := strings.Cut(key, "/")
+		if !found || !strings.HasSuffix(nadAnnotation, defaultNetworkAnnotation) || setting != securityGroupsAnnotation {
+			continue
+		}
+
+		for _, securityGroup := range strings.Split(value, ",") {
+			securityGroups[strings.TrimSpace(securityGroup)] = true
+		}
+	}
+
+	return sortedNames(securityGroups)
+}
+
+// StripAllocationAnnotations removes from a set of annotations the results of a Kube-OVN allocation, for the default
+// network as well as for every NAD. Returns the sorted keys of the removed annotations.
+func StripAllocationAnnotations(annotations map[string]string) []string {
+	var removed []string
+	for key := range annotations {
+		nadAnnotation, setting, found := strings.Cut(key, "/")
+		if !found || !strings.HasSuffix(nadAnnotation, defaultNetworkAnnotation) || !allocationAnnotations[setting] {
+			continue
+		}
+
+		delete(annotations, key)
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+
+	return removed
+}
+
+// GetSubnet retrieves a Kube-OVN subnet of the cluster. Returns nil if it doesn't exist.
+func GetSubnet(name string) (*kubeovnv1.Subnet, error) {
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	subnet, err := client.KubeovnV1().Subnets().Get(context.Background(), name, v1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve subnet %s: %w", name, err)
+	}
+
+	return subnet, nil
+}
+
+// GetSubnetForIPs retrieves the Kube-OVN subnet whose CIDR block contains every address of a comma-separated IP list.
+// The preferred subnet, if any, wins over the others, as the CIDRs of the subnets of distinct VPCs may overlap.
+// Returns nil if no subnet of the cluster can host those addresses.
+func GetSubnetForIPs(ips, preferred string) (*kubeovnv1.Subnet, error) {
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	subnets, err := client.KubeovnV1().Subnets().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the Kube-OVN subnets: %w", err)
+	}
+
+	var found *kubeovnv1.Subnet
+	for i := range subnets.Items {
+		if !subnetContainsIPs(&subnets.Items[i], ips) {
+			continue
+		}
+
+		if subnets.Items[i].Name == preferred {
+			return &subnets.Items[i], nil
+		}
+		if found == nil {
+			found = &subnets.Items[i]
+		}
+	}
+
+	return found, nil
+}
+
+// GetConflictingIPs retrieves the Kube-OVN IP custom resources of the subnet that already hold one of the addresses
+// of a comma-separated IP list on behalf of another pod than the given VM.
+func GetConflictingIPs(ips, subnet, vmName, vmNamespace string) ([]kubeovnv1.IP, error) {
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	list, err := client.KubeovnV1().IPs().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the Kube-OVN IPs: %w", err)
+	}
+
+	addresses := strings.Split(ips, ",")
+	var conflicts []kubeovnv1.IP
+	for _, ip := range list.Items {
+		if ip.Spec.PodName == vmName && ip.Spec.Namespace == vmNamespace {
+			continue
+		}
+		if subnet != "" && ip.Spec.Subnet != subnet {
+			continue
+		}
+
+		for _, address := range addresses {
+			if address == ip.Spec.V4IPAddress || address == ip.Spec.V6IPAddress {
+				conflicts = append(conflicts, ip)
+				break
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+// ReserveIPForVM pre-creates the Kube-OVN IP custom resource of a VM interface, holding its persisted addresses in
+// the subnet until the VM starts. Kube-OVN treats such an IP as reserved, and adopts it when the pod of the VM is
+// created, as it has the name Kube-OVN would give to the IP of that interface.
+// Returns false if the IP custom resource already exists, in which case it is left untouched.
+func ReserveIPForVM(netInfo *NetInfo, subnet, vmName, vmNamespace string, labels map[string]string) (bool, error) {
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return false, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	ipName, err := getIPCRNameForVM(netInfo.NADAnnotation, vmName, vmNamespace)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve IP name for VM %s/%s: %w", vmNamespace, vmName, err)
+	}
+
+	ip := &kubeovnv1.IP{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   ipName,
+			Labels: labels,
+		},
+		Spec: kubeovnv1.IPSpec{
+			PodName:    vmName,
+			Namespace:  vmNamespace,
+			Subnet:     subnet,
+			MacAddress: netInfo.MAC,
+			IPAddress:  netInfo.IPs,
+			PodType:    VirtualMachinePodType,
+		},
+	}
+	for _, address := range strings.Split(netInfo.IPs, ",") {
+		if parsed := net.ParseIP(address); parsed != nil && parsed.To4() != nil {
+			ip.Spec.V4IPAddress = address
+		} else {
+			ip.Spec.V6IPAddress = address
+		}
+	}
+
+	_, err = client.KubeovnV1().IPs().Create(context.Background(), ip, v1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve IP %s for VM %s/%s: %w", ipName, vmNamespace, vmName, err)
+	}
+
+	return true, nil
+}
+
+// ReleaseIPForVM deletes the Kube-OVN IP custom resource of a VM interface, releasing its addresses in the subnet.
+// It is meant to undo ReserveIPForVM, an IP that doesn't exist anymore is not an error.
+func ReleaseIPForVM(nadAnnotation, vmName, vmNamespace string) error {
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	ipName, err := getIPCRNameForVM(nadAnnotation, vmName, vmNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve IP name for VM %s/%s: %w", vmNamespace, vmName, err)
+	}
+
+	err = client.KubeovnV1().IPs().Delete(context.Background(), ipName, v1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to release IP %s of VM %s/%s: %w", ipName, vmNamespace, vmName, err)
+	}
+
+	return nil
+}
+
+// subnetContainsIPs checks whether every address of a comma-separated IP list belongs to one of the CIDRs of a subnet.
+// The CIDR block of a dual-stack subnet is itself a comma-separated list.
+func subnetContainsIPs(subnet *kubeovnv1.Subnet, ips string) bool {
+	var cidrs []*net.IPNet
+	for _, block := range strings.Split(subnet.Spec.CIDRBlock, ",") {
+		_, cidr, err := net.ParseCIDR(block)
+		if err != nil {
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	for _, address := range strings.Split(ips, ",") {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return false
+		}
+
+		contained := false
+		for _, cidr := range cidrs {
+			if cidr.Contains(ip) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			return false
+		}
+	}
+
+	return true
+}
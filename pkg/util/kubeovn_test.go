@@ -3,11 +3,16 @@ package util
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
 	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
 )
 
 func TestGetIPNameForDefaultNetwork(t *testing.T) {
@@ -88,16 +93,24 @@ func TestGetIPNameForNADNetwork(t *testing.T) {
 			wantErr:       true,
 		},
 		{
-			name:          "invalid nad annotation pattern (missing namespace)",
-			nadAnnotation: "test-nad.ovn.kubernetes.io",
+			name:          "custom provider",
+			nadAnnotation: "tenant.ovn.kubernetes.io",
 			vmName:        "test-vm",
 			vmNamespace:   "test-ns",
-			want:          "",
-			wantErr:       true,
+			want:          "test-vm.test-ns.tenant.ovn",
+			wantErr:       false,
 		},
 		{
-			name:          "nad namespace mismatch with vm namespace",
-			nadAnnotation: "test-nad.other-ns.ovn.kubernetes.io",
+			name:          "nad in another namespace than the vm",
+			nadAnnotation: "shared-nad.networks.ovn.kubernetes.io",
+			vmName:        "test-vm",
+			vmNamespace:   "test-ns",
+			want:          "test-vm.test-ns.shared-nad.networks.ovn",
+			wantErr:       false,
+		},
+		{
+			name:          "invalid nad annotation pattern (empty namespace)",
+			nadAnnotation: "test-nad..ovn.kubernetes.io",
 			vmName:        "test-vm",
 			vmNamespace:   "test-ns",
 			want:          "",
@@ -120,10 +133,18 @@ func TestGetIPNameForNADNetwork(t *testing.T) {
 			wantErr:       true,
 		},
 		{
-			name:          "annotation too long (more than 2 parts before suffix)",
+			name:          "custom provider with several labels",
 			nadAnnotation: "part1.part2.part3.ovn.kubernetes.io",
 			vmName:        "test-vm",
 			vmNamespace:   "test-ns",
+			want:          "test-vm.test-ns.part1.part2.part3.ovn",
+			wantErr:       false,
+		},
+		{
+			name:          "provider not managed by OVN",
+			nadAnnotation: "test-nad.test-ns.kubernetes.io",
+			vmName:        "test-vm",
+			vmNamespace:   "test-ns",
 			want:          "",
 			wantErr:       true,
 		},
@@ -185,10 +206,18 @@ func TestGetIPCRNameForVM(t *testing.T) {
 			wantErr:       true,
 		},
 		{
-			name:          "error from getIPNameForNADNetwork (namespace mismatch)",
+			name:          "NAD network in another namespace",
 			nadAnnotation: "test-nad.other-ns.ovn.kubernetes.io",
 			vmName:        "test-vm",
 			vmNamespace:   "test-ns",
+			want:          "test-vm.test-ns.test-nad.other-ns.ovn",
+			wantErr:       false,
+		},
+		{
+			name:          "error from getIPNameForNADNetwork (invalid NAD annotation)",
+			nadAnnotation: "test-nad..ovn.kubernetes.io",
+			vmName:        "test-vm",
+			vmNamespace:   "test-ns",
 			want:          "",
 			wantErr:       true,
 		},
@@ -375,21 +404,37 @@ func TestNetworkNameToNadAnnotation(t *testing.T) {
 	tests := []struct {
 		name        string
 		networkName string
+		vmNamespace string
 		want        string
 		wantErr     bool
 	}{
 		{
 			name:        "valid network name",
 			networkName: "test-ns/test-nad",
+			vmNamespace: "vm-ns",
 			want:        "test-nad.test-ns.ovn.kubernetes.io",
 			wantErr:     false,
 		},
 		{
-			name:        "invalid network name (missing slash)",
+			name:        "bare NAD name resolved in the VM namespace",
+			networkName: "test-nad",
+			vmNamespace: "vm-ns",
+			want:        "test-nad.vm-ns.ovn.kubernetes.io",
+			wantErr:     false,
+		},
+		{
+			name:        "bare NAD name without VM namespace",
 			networkName: "test-ns-test-nad",
 			want:        "",
 			wantErr:     true,
 		},
+		{
+			name:        "invalid network name (empty namespace)",
+			networkName: "/test-nad",
+			vmNamespace: "vm-ns",
+			want:        "",
+			wantErr:     true,
+		},
 		{
 			name:        "invalid network name (too many slashes)",
 			networkName: "test-ns/test-nad/extra",
@@ -399,6 +444,7 @@ func TestNetworkNameToNadAnnotation(t *testing.T) {
 		{
 			name:        "empty network name",
 			networkName: "",
+			vmNamespace: "vm-ns",
 			want:        "",
 			wantErr:     true,
 		},
@@ -406,7 +452,7 @@ func TestNetworkNameToNadAnnotation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := NetworkNameToNadAnnotation(tt.networkName)
+			got, err := NetworkNameToNadAnnotation(tt.networkName, tt.vmNamespace)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NetworkNameToNadAnnotation() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -418,6 +464,102 @@ func TestNetworkNameToNadAnnotation(t *testing.T) {
 	}
 }
 
+func TestValidateNetworkName(t *testing.T) {
+	tests := []struct {
+		name        string
+		networkName string
+		wantErr     bool
+	}{
+		{
+			name:        "network name with a namespace",
+			networkName: "test-ns/test-nad",
+			wantErr:     false,
+		},
+		{
+			name:        "bare NAD name",
+			networkName: "test-nad",
+			wantErr:     false,
+		},
+		{
+			name:        "empty namespace",
+			networkName: "/test-nad",
+			wantErr:     true,
+		},
+		{
+			name:        "too many slashes",
+			networkName: "test-ns/test-nad/extra",
+			wantErr:     true,
+		},
+		{
+			name:        "empty network name",
+			networkName: "",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateNetworkName(tt.networkName); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNetworkName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSameNetwork(t *testing.T) {
+	tests := []struct {
+		name             string
+		networkName      string
+		otherNetworkName string
+		vmNamespace      string
+		want             bool
+	}{
+		{
+			name:             "same network name",
+			networkName:      "test-ns/test-nad",
+			otherNetworkName: "test-ns/test-nad",
+			vmNamespace:      "vm-ns",
+			want:             true,
+		},
+		{
+			name:             "bare NAD name in the VM namespace",
+			networkName:      "test-nad",
+			otherNetworkName: "vm-ns/test-nad",
+			vmNamespace:      "vm-ns",
+			want:             true,
+		},
+		{
+			name:             "bare NAD name in another namespace",
+			networkName:      "test-nad",
+			otherNetworkName: "test-ns/test-nad",
+			vmNamespace:      "vm-ns",
+			want:             false,
+		},
+		{
+			name:             "other NAD",
+			networkName:      "test-ns/test-nad",
+			otherNetworkName: "test-ns/other-nad",
+			vmNamespace:      "vm-ns",
+			want:             false,
+		},
+		{
+			name:             "malformed network name",
+			networkName:      "test-ns/test-nad/extra",
+			otherNetworkName: "test-ns/test-nad/extra",
+			vmNamespace:      "vm-ns",
+			want:             false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameNetwork(tt.networkName, tt.otherNetworkName, tt.vmNamespace); got != tt.want {
+				t.Errorf("SameNetwork() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestIPToNetInfo(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -441,6 +583,23 @@ func TestIPToNetInfo(t *testing.T) {
 				IPs:           "10.0.0.1,fd00::1",
 			},
 		},
+		{
+			name:          "subnet",
+			nadAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+			ip: kubeovnv1.IP{
+				Spec: kubeovnv1.IPSpec{
+					MacAddress:  "00:00:00:00:00:05",
+					V4IPAddress: "10.0.0.5",
+					Subnet:      "test-subnet",
+				},
+			},
+			want: &NetInfo{
+				NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+				MAC:           "00:00:00:00:00:05",
+				IPs:           "10.0.0.5",
+				Subnet:        "test-subnet",
+			},
+		},
 		{
 			name:          "ipv4 only",
 			nadAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
@@ -503,6 +662,9 @@ func TestIPToNetInfo(t *testing.T) {
 			if got.IPs != tt.want.IPs {
 				t.Errorf("IPToNetInfo() IPs = %v, want %v", got.IPs, tt.want.IPs)
 			}
+			if got.Subnet != tt.want.Subnet {
+				t.Errorf("IPToNetInfo() Subnet = %v, want %v", got.Subnet, tt.want.Subnet)
+			}
 		})
 	}
 }
@@ -538,15 +700,58 @@ func TestNetInfoToAnnotations(t *testing.T) {
 			},
 		},
 		{
-			name: "empty values",
+			name: "logical switch",
+			netInfo: NetInfo{
+				NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+				MAC:           "00:00:00:00:00:02",
+				IPs:           "10.0.0.2",
+				Subnet:        "test-subnet",
+			},
+			want: map[string]string{
+				"test-nad.test-ns.ovn.kubernetes.io/mac_address":    "00:00:00:00:00:02",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address":     "10.0.0.2",
+				"test-nad.test-ns.ovn.kubernetes.io/logical_switch": "test-subnet",
+			},
+		},
+		{
+			name: "security groups",
+			netInfo: NetInfo{
+				NADAnnotation:  "ovn.kubernetes.io",
+				MAC:            "00:00:00:00:00:01",
+				IPs:            "10.0.0.1",
+				SecurityGroups: "sg-web,sg-ssh",
+			},
+			want: map[string]string{
+				"ovn.kubernetes.io/mac_address":     "00:00:00:00:00:01",
+				"ovn.kubernetes.io/ip_address":      "10.0.0.1",
+				"ovn.kubernetes.io/security_groups": "sg-web,sg-ssh",
+			},
+		},
+		{
+			name: "bandwidth limits",
+			netInfo: NetInfo{
+				NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+				MAC:           "00:00:00:00:00:02",
+				IPs:           "10.0.0.2",
+				IngressRate:   "100",
+				EgressRate:    "50",
+			},
+			want: map[string]string{
+				"test-nad.test-ns.ovn.kubernetes.io/mac_address":  "00:00:00:00:00:02",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address":   "10.0.0.2",
+				"test-nad.test-ns.ovn.kubernetes.io/ingress_rate": "100",
+				"test-nad.test-ns.ovn.kubernetes.io/egress_rate":  "50",
+			},
+		},
+		{
+			name: "empty MAC address is not persisted",
 			netInfo: NetInfo{
 				NADAnnotation: "ovn.kubernetes.io",
 				MAC:           "",
-				IPs:           ",",
+				IPs:           "10.0.0.1",
 			},
 			want: map[string]string{
-				"ovn.kubernetes.io/mac_address": "",
-				"ovn.kubernetes.io/ip_address":  ",",
+				"ovn.kubernetes.io/ip_address": "10.0.0.1",
 			},
 		},
 	}
@@ -565,3 +770,1013 @@ func TestNetInfoToAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestNetInfosFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []NetInfo
+	}{
+		{
+			name: "default network and NAD network",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
+				"ovn.kubernetes.io/ip_address":                   "10.0.0.1,fd00::1",
+				"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:02",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.2",
+			},
+			want: []NetInfo{
+				{
+					NADAnnotation: "ovn.kubernetes.io",
+					Source:        NetInfoSourceAnnotations,
+					MAC:           "00:00:00:00:00:01",
+					IPs:           "10.0.0.1,fd00::1",
+				},
+				{
+					NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+					Source:        NetInfoSourceAnnotations,
+					MAC:           "00:00:00:00:00:02",
+					IPs:           "10.0.0.2",
+				},
+			},
+		},
+		{
+			name: "unrelated annotations are ignored",
+			annotations: map[string]string{
+				"existing.annotation":           "preserved",
+				"ovn.kubernetes.io/routes":      "[]",
+				"kubevirt.io/ip_address":        "10.0.0.1",
+				"ovn.kubernetes.io/ip_address":  "10.0.0.3",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:03",
+			},
+			want: []NetInfo{
+				{
+					NADAnnotation: "ovn.kubernetes.io",
+					Source:        NetInfoSourceAnnotations,
+					MAC:           "00:00:00:00:00:03",
+					IPs:           "10.0.0.3",
+				},
+			},
+		},
+		{
+			name: "logical switch",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/mac_address":                     "00:00:00:00:00:05",
+				"ovn.kubernetes.io/ip_address":                      "10.0.0.5",
+				"ovn.kubernetes.io/logical_switch":                  "ovn-default",
+				"test-nad.test-ns.ovn.kubernetes.io/logical_switch": "test-subnet",
+			},
+			want: []NetInfo{
+				{
+					NADAnnotation: "ovn.kubernetes.io",
+					Source:        NetInfoSourceAnnotations,
+					MAC:           "00:00:00:00:00:05",
+					IPs:           "10.0.0.5",
+					Subnet:        "ovn-default",
+				},
+			},
+		},
+		{
+			name: "MAC address only",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:04",
+			},
+			want: []NetInfo{
+				{
+					NADAnnotation: "ovn.kubernetes.io",
+					Source:        NetInfoSourceAnnotations,
+					MAC:           "00:00:00:00:00:04",
+				},
+			},
+		},
+		{
+			name:        "nil annotations",
+			annotations: nil,
+			want:        []NetInfo{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NetInfosFromAnnotations(tt.annotations)
+			if len(got) != len(tt.want) {
+				t.Fatalf("NetInfosFromAnnotations() got %d NetInfos, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("NetInfosFromAnnotations() got[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNetInfoValidateMAC(t *testing.T) {
+	tests := []struct {
+		name    string
+		mac     string
+		wantErr bool
+	}{
+		{
+			name:    "valid MAC address",
+			mac:     "00:00:00:00:00:01",
+			wantErr: false,
+		},
+		{
+			name:    "malformed MAC address",
+			mac:     "00:00:00:00:01",
+			wantErr: true,
+		},
+		{
+			name:    "multicast MAC address",
+			mac:     "01:00:5e:00:00:01",
+			wantErr: true,
+		},
+		{
+			name:    "empty MAC address",
+			mac:     "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netInfo := NetInfo{NADAnnotation: "ovn.kubernetes.io", MAC: tt.mac}
+			if err := netInfo.ValidateMAC(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMAC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetInfoValidateIPs(t *testing.T) {
+	tests := []struct {
+		name    string
+		ips     string
+		wantErr bool
+	}{
+		{
+			name:    "ipv4 only",
+			ips:     "10.0.0.1",
+			wantErr: false,
+		},
+		{
+			name:    "dual stack",
+			ips:     "10.0.0.1,fd00::1",
+			wantErr: false,
+		},
+		{
+			name:    "invalid address",
+			ips:     "10.0.0.300",
+			wantErr: true,
+		},
+		{
+			name:    "trailing comma",
+			ips:     "10.0.0.1,",
+			wantErr: true,
+		},
+		{
+			name:    "too many addresses",
+			ips:     "10.0.0.1,10.0.0.2,fd00::1",
+			wantErr: true,
+		},
+		{
+			name:    "two IPv4 addresses",
+			ips:     "10.0.0.1,10.0.0.2",
+			wantErr: true,
+		},
+		{
+			name:    "two IPv6 addresses",
+			ips:     "fd00::1,fd00::2",
+			wantErr: true,
+		},
+		{
+			name:    "IPv4-mapped IPv6 address along with an IPv4 address",
+			ips:     "10.0.0.1,::ffff:10.0.0.2",
+			wantErr: true,
+		},
+		{
+			name:    "empty IPs",
+			ips:     "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netInfo := NetInfo{NADAnnotation: "ovn.kubernetes.io", IPs: tt.ips}
+			if err := netInfo.ValidateIPs(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateIPs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSubnetForIPs(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	subnets := []*kubeovnv1.Subnet{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ovn-default"},
+			Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "10.16.0.0/16"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "dual-stack"},
+			Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "10.17.0.0/16,fd00:10:17::/64"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"},
+			Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "192.168.0.0/24", Vpc: "tenant-a"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"},
+			Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "192.168.0.0/24", Vpc: "tenant-b"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		ips        string
+		preferred  string
+		wantSubnet string
+	}{
+		{
+			name:       "ipv4 address in the default subnet",
+			ips:        "10.16.0.12",
+			wantSubnet: "ovn-default",
+		},
+		{
+			name:       "dual stack addresses",
+			ips:        "10.17.0.12,fd00:10:17::12",
+			wantSubnet: "dual-stack",
+		},
+		{
+			name:       "ipv6 address outside of the dual stack subnet",
+			ips:        "10.17.0.12,fd00:10:18::12",
+			wantSubnet: "",
+		},
+		{
+			name:       "address outside of every subnet",
+			ips:        "172.16.0.1",
+			wantSubnet: "",
+		},
+		{
+			name:       "overlapping subnets with a preferred one",
+			ips:        "192.168.0.1",
+			preferred:  "tenant-b",
+			wantSubnet: "tenant-b",
+		},
+		{
+			name:       "preferred subnet not containing the address",
+			ips:        "10.16.0.12",
+			preferred:  "tenant-b",
+			wantSubnet: "ovn-default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewSimpleClientset()
+			for _, subnet := range subnets {
+				_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), subnet, metav1.CreateOptions{})
+			}
+			GetKubeOvnClient = func() (KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			got, err := GetSubnetForIPs(tt.ips, tt.preferred)
+			if err != nil {
+				t.Fatalf("GetSubnetForIPs() unexpected error = %v", err)
+			}
+			if tt.wantSubnet == "" {
+				if got != nil {
+					t.Errorf("GetSubnetForIPs() got subnet %s, want none", got.Name)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.wantSubnet {
+				t.Errorf("GetSubnetForIPs() got %v, want subnet %s", got, tt.wantSubnet)
+			}
+		})
+	}
+}
+
+func TestGetSubnet(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	fakeClient := fake.NewSimpleClientset()
+	_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), &kubeovnv1.Subnet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ovn-default"},
+		Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "10.16.0.0/16"},
+	}, metav1.CreateOptions{})
+	GetKubeOvnClient = func() (KubeOvnClient, error) {
+		return fakeClient, nil
+	}
+
+	tests := []struct {
+		name       string
+		subnet     string
+		wantExists bool
+	}{
+		{
+			name:       "existing subnet",
+			subnet:     "ovn-default",
+			wantExists: true,
+		},
+		{
+			name:       "missing subnet",
+			subnet:     "missing",
+			wantExists: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetSubnet(tt.subnet)
+			if err != nil {
+				t.Fatalf("GetSubnet() unexpected error = %v", err)
+			}
+			if (got != nil) != tt.wantExists {
+				t.Errorf("GetSubnet() got %v, wantExists %v", got, tt.wantExists)
+			}
+		})
+	}
+}
+
+func TestRemapNadAnnotation(t *testing.T) {
+	// Mock GetNetworkClient, tenant-nad configures a provider looking like the default one of another NAD
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	nadClient := nadfake.NewSimpleClientset()
+	_, _ = nadClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions("tenant-ns").Create(context.Background(), &nadv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-nad", Namespace: "tenant-ns"},
+		Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.0", "type": "kube-ovn", "provider": "shared.old-ns.ovn"}`},
+	}, metav1.CreateOptions{})
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadClient, nil
+	}
+
+	namespaceMapping := map[string]string{"old-ns": "new-ns"}
+
+	tests := []struct {
+		name          string
+		nadAnnotation string
+		want          string
+		wantErr       bool
+	}{
+		{
+			name:          "default network annotation",
+			nadAnnotation: "ovn.kubernetes.io",
+			want:          "ovn.kubernetes.io",
+			wantErr:       false,
+		},
+		{
+			name:          "NAD in a mapped namespace",
+			nadAnnotation: "test-nad.old-ns.ovn.kubernetes.io",
+			want:          "test-nad.new-ns.ovn.kubernetes.io",
+			wantErr:       false,
+		},
+		{
+			name:          "NAD in an unmapped namespace",
+			nadAnnotation: "test-nad.other-ns.ovn.kubernetes.io",
+			want:          "test-nad.other-ns.ovn.kubernetes.io",
+			wantErr:       false,
+		},
+		{
+			name:          "custom provider",
+			nadAnnotation: "tenant.old-ns.custom.ovn.kubernetes.io",
+			want:          "tenant.old-ns.custom.ovn.kubernetes.io",
+			wantErr:       false,
+		},
+		{
+			name:          "custom provider looking like a default one",
+			nadAnnotation: "shared.old-ns.ovn.kubernetes.io",
+			want:          "shared.old-ns.ovn.kubernetes.io",
+			wantErr:       false,
+		},
+		{
+			name:          "invalid NAD annotation",
+			nadAnnotation: "test-nad..ovn.kubernetes.io",
+			want:          "",
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemapNadAnnotation(tt.nadAnnotation, namespaceMapping)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RemapNadAnnotation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("RemapNadAnnotation() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemapNetworkName(t *testing.T) {
+	namespaceMapping := map[string]string{"old-ns": "new-ns"}
+
+	tests := []struct {
+		name        string
+		networkName string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "NAD in a mapped namespace",
+			networkName: "old-ns/test-nad",
+			want:        "new-ns/test-nad",
+			wantErr:     false,
+		},
+		{
+			name:        "NAD in an unmapped namespace",
+			networkName: "other-ns/test-nad",
+			want:        "other-ns/test-nad",
+			wantErr:     false,
+		},
+		{
+			name:        "bare NAD name following the VM",
+			networkName: "test-nad",
+			want:        "test-nad",
+			wantErr:     false,
+		},
+		{
+			name:        "invalid network name",
+			networkName: "old-ns/test-nad/extra",
+			want:        "",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemapNetworkName(tt.networkName, namespaceMapping)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RemapNetworkName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("RemapNetworkName() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemapNadAnnotations(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadfake.NewSimpleClientset(), nil
+	}
+
+	tests := []struct {
+		name             string
+		annotations      map[string]string
+		namespaceMapping map[string]string
+		want             map[string]string
+	}{
+		{
+			name: "NAD annotations are remapped",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                 "10.0.0.1",
+				"test-nad.old-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+				"test-nad.old-ns.ovn.kubernetes.io/routes":     "[]",
+				"existing.annotation":                          "preserved",
+			},
+			namespaceMapping: map[string]string{"old-ns": "new-ns"},
+			want: map[string]string{
+				"ovn.kubernetes.io/ip_address":                 "10.0.0.1",
+				"test-nad.new-ns.ovn.kubernetes.io/ip_address": "10.0.0.2",
+				"test-nad.new-ns.ovn.kubernetes.io/routes":     "[]",
+				"existing.annotation":                          "preserved",
+			},
+		},
+		{
+			name: "swapped namespaces",
+			annotations: map[string]string{
+				"test-nad.ns-a.ovn.kubernetes.io/ip_address": "10.0.0.1",
+				"test-nad.ns-b.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+			namespaceMapping: map[string]string{"ns-a": "ns-b", "ns-b": "ns-a"},
+			want: map[string]string{
+				"test-nad.ns-b.ovn.kubernetes.io/ip_address": "10.0.0.1",
+				"test-nad.ns-a.ovn.kubernetes.io/ip_address": "10.0.0.2",
+			},
+		},
+		{
+			name: "custom providers are kept",
+			annotations: map[string]string{
+				"part1.part2.part3.ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+			namespaceMapping: map[string]string{"part2": "other"},
+			want: map[string]string{
+				"part1.part2.part3.ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+		},
+		{
+			name: "unparsable annotations are kept",
+			annotations: map[string]string{
+				"part1..ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+			namespaceMapping: map[string]string{"part2": "other"},
+			want: map[string]string{
+				"part1..ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemapNadAnnotations(tt.annotations, tt.namespaceMapping)
+			if err != nil {
+				t.Fatalf("RemapNadAnnotations() unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("RemapNadAnnotations() got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("RemapNadAnnotations() key %s: got %s, want %s", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestGetConflictingIPs(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	existingIPs := []*kubeovnv1.IP{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns"},
+			Spec: kubeovnv1.IPSpec{
+				PodName:     "test-vm",
+				Namespace:   "test-ns",
+				Subnet:      "ovn-default",
+				V4IPAddress: "10.16.0.1",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-vm.test-ns"},
+			Spec: kubeovnv1.IPSpec{
+				PodName:     "other-vm",
+				Namespace:   "test-ns",
+				Subnet:      "ovn-default",
+				V4IPAddress: "10.16.0.2",
+				V6IPAddress: "fd00::2",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "vpc-vm.vpc-ns"},
+			Spec: kubeovnv1.IPSpec{
+				PodName:     "vpc-vm",
+				Namespace:   "vpc-ns",
+				Subnet:      "vpc-subnet",
+				V4IPAddress: "10.16.0.3",
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		ips           string
+		subnet        string
+		wantConflicts []string
+	}{
+		{
+			name:          "address held by the VM itself",
+			ips:           "10.16.0.1",
+			subnet:        "ovn-default",
+			wantConflicts: nil,
+		},
+		{
+			name:          "address held by another pod",
+			ips:           "10.16.0.2",
+			subnet:        "ovn-default",
+			wantConflicts: []string{"other-vm.test-ns"},
+		},
+		{
+			name:          "ipv6 address held by another pod",
+			ips:           "10.16.0.20,fd00::2",
+			subnet:        "ovn-default",
+			wantConflicts: []string{"other-vm.test-ns"},
+		},
+		{
+			name:          "same address in another subnet",
+			ips:           "10.16.0.3",
+			subnet:        "ovn-default",
+			wantConflicts: nil,
+		},
+		{
+			name:          "free address",
+			ips:           "10.16.0.4",
+			subnet:        "ovn-default",
+			wantConflicts: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			GetKubeOvnClient = func() (KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			got, err := GetConflictingIPs(tt.ips, tt.subnet, "test-vm", "test-ns")
+			if err != nil {
+				t.Fatalf("GetConflictingIPs() unexpected error = %v", err)
+			}
+			if len(got) != len(tt.wantConflicts) {
+				t.Fatalf("GetConflictingIPs() got %d conflicts, want %d", len(got), len(tt.wantConflicts))
+			}
+			for i, name := range tt.wantConflicts {
+				if got[i].Name != name {
+					t.Errorf("GetConflictingIPs() got conflict %s, want %s", got[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestNetInfoDeleteAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
+		"ovn.kubernetes.io/ip_address":                   "10.0.0.1",
+		"ovn.kubernetes.io/routes":                       "[]",
+		"ovn.kubernetes.io/security_groups":              "sg-web",
+		"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:02",
+		"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.2",
+	}
+
+	// The security groups are kept, even if the NetInfo carries them
+	netInfo := NetInfo{NADAnnotation: "ovn.kubernetes.io", SecurityGroups: "sg-web"}
+	netInfo.DeleteAnnotations(annotations)
+
+	want := map[string]string{
+		"ovn.kubernetes.io/routes":                       "[]",
+		"ovn.kubernetes.io/security_groups":              "sg-web",
+		"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:02",
+		"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.2",
+	}
+	if len(annotations) != len(want) {
+		t.Errorf("DeleteAnnotations() got %v, want %v", annotations, want)
+	}
+	for k, v := range want {
+		if annotations[k] != v {
+			t.Errorf("DeleteAnnotations() key %s: got %s, want %s", k, annotations[k], v)
+		}
+	}
+}
+
+func TestReserveIPForVM(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	tests := []struct {
+		name        string
+		netInfo     NetInfo
+		existingIPs []*kubeovnv1.IP
+		wantIPName  string
+		wantSpec    kubeovnv1.IPSpec
+		wantCreated bool
+		wantErr     bool
+	}{
+		{
+			name: "reserve dual stack addresses on the default network",
+			netInfo: NetInfo{
+				NADAnnotation: "ovn.kubernetes.io",
+				MAC:           "00:00:00:00:00:01",
+				IPs:           "10.16.0.1,fd00::1",
+			},
+			wantIPName: "test-vm.test-ns",
+			wantSpec: kubeovnv1.IPSpec{
+				PodName:     "test-vm",
+				Namespace:   "test-ns",
+				Subnet:      "test-subnet",
+				MacAddress:  "00:00:00:00:00:01",
+				IPAddress:   "10.16.0.1,fd00::1",
+				V4IPAddress: "10.16.0.1",
+				V6IPAddress: "fd00::1",
+				PodType:     "VirtualMachine",
+			},
+			wantCreated: true,
+			wantErr:     false,
+		},
+		{
+			name: "reserve an address on a NAD network",
+			netInfo: NetInfo{
+				NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+				IPs:           "fd00::2",
+			},
+			wantIPName: "test-vm.test-ns.test-nad.test-ns.ovn",
+			wantSpec: kubeovnv1.IPSpec{
+				PodName:     "test-vm",
+				Namespace:   "test-ns",
+				Subnet:      "test-subnet",
+				IPAddress:   "fd00::2",
+				V6IPAddress: "fd00::2",
+				PodType:     "VirtualMachine",
+			},
+			wantCreated: true,
+			wantErr:     false,
+		},
+		{
+			name: "IP already exists",
+			netInfo: NetInfo{
+				NADAnnotation: "ovn.kubernetes.io",
+				IPs:           "10.16.0.1",
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns"},
+					Spec: kubeovnv1.IPSpec{
+						PodName:     "test-vm",
+						Namespace:   "test-ns",
+						V4IPAddress: "10.16.0.1",
+						ContainerID: "running",
+					},
+				},
+			},
+			wantIPName: "test-vm.test-ns",
+			wantSpec: kubeovnv1.IPSpec{
+				PodName:     "test-vm",
+				Namespace:   "test-ns",
+				V4IPAddress: "10.16.0.1",
+				ContainerID: "running",
+			},
+			wantCreated: false,
+			wantErr:     false,
+		},
+		{
+			name: "invalid network annotation",
+			netInfo: NetInfo{
+				NADAnnotation: "invalid",
+				IPs:           "10.16.0.1",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			GetKubeOvnClient = func() (KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			created, err := ReserveIPForVM(&tt.netInfo, "test-subnet", "test-vm", "test-ns", map[string]string{"test": "label"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReserveIPForVM() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if created != tt.wantCreated {
+				t.Errorf("ReserveIPForVM() created = %v, want %v", created, tt.wantCreated)
+			}
+
+			ip, err := fakeClient.KubeovnV1().IPs().Get(context.Background(), tt.wantIPName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("ReserveIPForVM() expected IP %s to exist: %v", tt.wantIPName, err)
+			}
+			if !reflect.DeepEqual(ip.Spec, tt.wantSpec) {
+				t.Errorf("ReserveIPForVM() got spec %+v, want %+v", ip.Spec, tt.wantSpec)
+			}
+			if tt.wantCreated && ip.Labels["test"] != "label" {
+				t.Errorf("ReserveIPForVM() expected the IP to carry the labels, got %v", ip.Labels)
+			}
+		})
+	}
+}
+
+func TestReleaseIPForVM(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	tests := []struct {
+		name          string
+		nadAnnotation string
+		existingIPs   []*kubeovnv1.IP
+		wantReleased  string
+		wantKept      []string
+		wantErr       bool
+	}{
+		{
+			name:          "release the IP of a NAD network",
+			nadAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+			existingIPs: []*kubeovnv1.IP{
+				{ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns.test-nad.test-ns.ovn"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns"}},
+			},
+			wantReleased: "test-vm.test-ns.test-nad.test-ns.ovn",
+			wantKept:     []string{"test-vm.test-ns"},
+			wantErr:      false,
+		},
+		{
+			name:          "IP already released",
+			nadAnnotation: "ovn.kubernetes.io",
+			wantReleased:  "test-vm.test-ns",
+			wantErr:       false,
+		},
+		{
+			name:          "invalid network annotation",
+			nadAnnotation: "invalid",
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			GetKubeOvnClient = func() (KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			err := ReleaseIPForVM(tt.nadAnnotation, "test-vm", "test-ns")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReleaseIPForVM() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if _, err := fakeClient.KubeovnV1().IPs().Get(context.Background(), tt.wantReleased, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Errorf("ReleaseIPForVM() expected IP %s to be deleted, got %v", tt.wantReleased, err)
+			}
+			for _, name := range tt.wantKept {
+				if _, err := fakeClient.KubeovnV1().IPs().Get(context.Background(), name, metav1.GetOptions{}); err != nil {
+					t.Errorf("ReleaseIPForVM() expected IP %s to be kept: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNetInfoMatches(t *testing.T) {
+	tests := []struct {
+		name         string
+		netInfo      NetInfo
+		other        NetInfo
+		wantMACMatch bool
+		wantIPsMatch bool
+	}{
+		{
+			name:         "identical",
+			netInfo:      NetInfo{MAC: "00:00:00:00:00:01", IPs: "10.0.0.1"},
+			other:        NetInfo{MAC: "00:00:00:00:00:01", IPs: "10.0.0.1"},
+			wantMACMatch: true,
+			wantIPsMatch: true,
+		},
+		{
+			name:         "different notations",
+			netInfo:      NetInfo{MAC: "0a:00:00:00:00:01", IPs: "fd00:0:0::1"},
+			other:        NetInfo{MAC: "0A-00-00-00-00-01", IPs: "fd00::1"},
+			wantMACMatch: true,
+			wantIPsMatch: true,
+		},
+		{
+			name:         "additional link-local address",
+			netInfo:      NetInfo{MAC: "00:00:00:00:00:01", IPs: "10.0.0.1,fd00::1"},
+			other:        NetInfo{MAC: "00:00:00:00:00:01", IPs: "10.0.0.1,fd00::1,fe80::1"},
+			wantMACMatch: true,
+			wantIPsMatch: true,
+		},
+		{
+			name:         "missing address",
+			netInfo:      NetInfo{MAC: "00:00:00:00:00:01", IPs: "10.0.0.1,fd00::1"},
+			other:        NetInfo{MAC: "00:00:00:00:00:02", IPs: "10.0.0.1"},
+			wantMACMatch: false,
+			wantIPsMatch: false,
+		},
+		{
+			name:         "empty live identity",
+			netInfo:      NetInfo{MAC: "00:00:00:00:00:01", IPs: "10.0.0.1"},
+			other:        NetInfo{},
+			wantMACMatch: false,
+			wantIPsMatch: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.netInfo.MACMatches(&tt.other); got != tt.wantMACMatch {
+				t.Errorf("MACMatches() got = %v, want %v", got, tt.wantMACMatch)
+			}
+			if got := tt.netInfo.IPsMatch(&tt.other); got != tt.wantIPsMatch {
+				t.Errorf("IPsMatch() got = %v, want %v", got, tt.wantIPsMatch)
+			}
+		})
+	}
+}
+
+func TestStripAllocationAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"ovn.kubernetes.io/allocated":                   "true",
+		"ovn.kubernetes.io/ip_address":                  "10.0.0.1",
+		"ovn.kubernetes.io/logical_switch":              "ovn-default",
+		"test-nad.test-ns.ovn.kubernetes.io/allocated":  "true",
+		"test-nad.test-ns.ovn.kubernetes.io/ip_address": "10.0.1.1",
+		"ovn.kubernetes.io/ingress_rate":                "100",
+		"kubevirt.io/domain":                            "test-vm",
+		"example.com/allocated":                         "true",
+	}
+
+	got := StripAllocationAnnotations(annotations)
+	want := []string{
+		"ovn.kubernetes.io/allocated",
+		"ovn.kubernetes.io/ip_address",
+		"ovn.kubernetes.io/logical_switch",
+		"test-nad.test-ns.ovn.kubernetes.io/allocated",
+		"test-nad.test-ns.ovn.kubernetes.io/ip_address",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StripAllocationAnnotations() got = %v, want %v", got, want)
+	}
+
+	wantAnnotations := map[string]string{
+		"ovn.kubernetes.io/ingress_rate": "100",
+		"kubevirt.io/domain":             "test-vm",
+		"example.com/allocated":          "true",
+	}
+	if !reflect.DeepEqual(annotations, wantAnnotations) {
+		t.Errorf("StripAllocationAnnotations() left annotations %v, want %v", annotations, wantAnnotations)
+	}
+}
+
+func TestNetInfoReadPodSettings(t *testing.T) {
+	podAnnotations := map[string]string{
+		"ovn.kubernetes.io/security_groups":                  "sg-web",
+		"ovn.kubernetes.io/ingress_rate":                     "100",
+		"ovn.kubernetes.io/egress_rate":                      "50",
+		"test-nad.test-ns.ovn.kubernetes.io/security_groups": "sg-ssh",
+	}
+
+	tests := []struct {
+		name    string
+		netInfo NetInfo
+		want    NetInfo
+	}{
+		{
+			name:    "default network",
+			netInfo: NetInfo{NADAnnotation: "ovn.kubernetes.io"},
+			want:    NetInfo{NADAnnotation: "ovn.kubernetes.io", SecurityGroups: "sg-web", IngressRate: "100", EgressRate: "50"},
+		},
+		{
+			name:    "NAD network",
+			netInfo: NetInfo{NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io"},
+			want:    NetInfo{NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io", SecurityGroups: "sg-ssh"},
+		},
+		{
+			name:    "no setting on the pod",
+			netInfo: NetInfo{NADAnnotation: "other-nad.test-ns.ovn.kubernetes.io", SecurityGroups: "sg-kept", IngressRate: "10"},
+			want:    NetInfo{NADAnnotation: "other-nad.test-ns.ovn.kubernetes.io", SecurityGroups: "sg-kept", IngressRate: "10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.netInfo.ReadPodSettings(podAnnotations)
+			if tt.netInfo != tt.want {
+				t.Errorf("ReadPodSettings() got %+v, want %+v", tt.netInfo, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecurityGroupsOfAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"ovn.kubernetes.io/security_groups":                  "sg-web, sg-ssh",
+		"test-nad.test-ns.ovn.kubernetes.io/security_groups": "sg-ssh,sg-db",
+		"ovn.kubernetes.io/ip_address":                       "10.0.0.1",
+		"example.com/security_groups":                        "sg-ignored",
+	}
+
+	got := SecurityGroupsOfAnnotations(annotations)
+	want := []string{"sg-db", "sg-ssh", "sg-web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SecurityGroupsOfAnnotations() got = %v, want %v", got, want)
+	}
+}
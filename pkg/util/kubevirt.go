@@ -1,73 +1,564 @@
 package util
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 
 	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	v1 "kubevirt.io/api/core/v1"
+	kvutil "kubevirt.io/kubevirt-velero-plugin/pkg/util"
 )
 
+// LauncherPodSelector selects the virt-launcher pods running the VMIs
+const LauncherPodSelector = "kubevirt.io=virt-launcher"
+
+// ListVMIs lists the VirtualMachineInstances of every namespace of the cluster.
+// This is assigned to a variable so it can be replaced by a mock function in tests
+var ListVMIs = func() ([]v1.VirtualMachineInstance, error) {
+	client, err := kvutil.GetKubeVirtclient()
+	if err != nil {
+		return nil, err
+	}
+
+	vmis, err := (*client).VirtualMachineInstance(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return vmis.Items, nil
+}
+
+// GetVM retrieves a VirtualMachine of the cluster.
+// This is assigned to a variable so it can be replaced by a mock function in tests
+var GetVM = func(namespace, name string) (*v1.VirtualMachine, error) {
+	client, err := kvutil.GetKubeVirtclient()
+	if err != nil {
+		return nil, err
+	}
+
+	return (*client).VirtualMachine(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+// GetVMI retrieves a VirtualMachineInstance of the cluster.
+// This is assigned to a variable so it can be replaced by a mock function in tests
+var GetVMI = func(namespace, name string) (*v1.VirtualMachineInstance, error) {
+	client, err := kvutil.GetKubeVirtclient()
+	if err != nil {
+		return nil, err
+	}
+
+	return (*client).VirtualMachineInstance(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+// NetInfosFromVMIStatus returns the network information of the interfaces reported in the status of a VMI.
+// Interfaces are matched to their network by name, interfaces of networks we cannot handle are ignored.
+// The MAC address is only returned for the interfaces bound through a bridge, see bridgeBound.
+func NetInfosFromVMIStatus(vmi *v1.VirtualMachineInstance) []NetInfo {
+	var netInfos []NetInfo
+	for _, iface := range vmi.Status.Interfaces {
+		nadAnnotation, ok := nadAnnotationForNetwork(vmi.Spec.Networks, iface.Name, vmi.Namespace)
+		if !ok {
+			continue
+		}
+
+		ips := iface.IPs
+		if len(ips) == 0 && iface.IP != "" {
+			ips = []string{iface.IP}
+		}
+
+		var mac string
+		if bridgeBound(vmi.Spec.Domain.Devices.Interfaces, iface.Name) {
+			mac = iface.MAC
+		}
+
+		netInfos = append(netInfos, NetInfo{
+			NADAnnotation: nadAnnotation,
+			MAC:           mac,
+			IPs:           strings.Join(ips, ","),
+			Source:        NetInfoSourceVMIStatus,
+		})
+	}
+
+	return netInfos
+}
+
+// bridgeBound checks whether the interface of a VMI with the given name is bound to its network through a bridge.
+// Only a bridge hands the MAC address Kube-OVN allocated to the pod over to the guest. The guest of the other
+// bindings, like masquerade, uses a MAC address of its own, which the VMI reports instead.
+func bridgeBound(interfaces []v1.Interface, name string) bool {
+	for _, iface := range interfaces {
+		if iface.Name == name {
+			return iface.Bridge != nil
+		}
+	}
+
+	return false
+}
+
+// nadAnnotationForNetwork returns the NAD annotation of the network of a VMI with the given name.
+// A VMI without networks is implicitly attached to the default network, through a network named "default".
+func nadAnnotationForNetwork(networks []v1.Network, name, vmiNamespace string) (string, bool) {
+	if len(networks) == 0 {
+		return defaultNetworkAnnotation, name == v1.DefaultPodNetwork().Name
+	}
+
+	for _, network := range networks {
+		if network.Name != name {
+			continue
+		}
+
+		if network.Pod != nil {
+			return defaultNetworkAnnotation, true
+		}
+		if network.Multus != nil {
+			nadAnnotation, err := ResolveNadAnnotation(network.Multus.NetworkName, vmiNamespace)
+			return nadAnnotation, err == nil
+		}
+	}
+
+	return "", false
+}
+
+// MACOwner describes a workload using a MAC address in the cluster
+type MACOwner struct {
+	Name      string
+	Namespace string
+	// Subnet is the logical switch of the interface, empty when it isn't known
+	Subnet string
+	IPs    []string
+}
+
+// MACIndex indexes the workloads of the cluster by the MAC addresses of their interfaces
+type MACIndex map[string][]MACOwner
+
+// BuildMACIndex indexes the MAC addresses found in the Kube-OVN IP custom resources and in the interfaces
+// reported by the VirtualMachineInstances of the cluster.
+func BuildMACIndex() (MACIndex, error) {
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	ips, err := client.KubeovnV1().IPs().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the Kube-OVN IPs: %w", err)
+	}
+
+	vmis, err := ListVMIs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the VMIs: %w", err)
+	}
+
+	index := make(MACIndex)
+	for _, ip := range ips.Items {
+		var addresses []string
+		if ip.Spec.V4IPAddress != "" {
+			addresses = append(addresses, ip.Spec.V4IPAddress)
+		}
+		if ip.Spec.V6IPAddress != "" {
+			addresses = append(addresses, ip.Spec.V6IPAddress)
+		}
+
+		index.add(ip.Spec.MacAddress, MACOwner{
+			Name:      ip.Spec.PodName,
+			Namespace: ip.Spec.Namespace,
+			Subnet:    ip.Spec.Subnet,
+			IPs:       addresses,
+		})
+	}
+
+	for _, vmi := range vmis {
+		for _, iface := range vmi.Status.Interfaces {
+			index.add(iface.MAC, MACOwner{
+				Name:      vmi.Name,
+				Namespace: vmi.Namespace,
+				IPs:       iface.IPs,
+			})
+		}
+	}
+
+	return index, nil
+}
+
+// add registers an owner for a MAC address, ignoring malformed addresses
+func (m MACIndex) add(mac string, owner MACOwner) {
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return
+	}
+
+	m[hwAddr.String()] = append(m[hwAddr.String()], owner)
+}
+
+// Conflicts returns the workloads other than the given VM that use a MAC address on the logical switch of a subnet.
+// When the subnet is nil, or when the logical switch of a workload isn't known, the workload is considered
+// to be on the same logical switch, as we cannot prove otherwise.
+func (m MACIndex) Conflicts(mac string, subnet *kubeovnv1.Subnet, vmName, vmNamespace string) []MACOwner {
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil
+	}
+
+	var conflicts []MACOwner
+	seen := make(map[string]bool)
+	for _, owner := range m[hwAddr.String()] {
+		if owner.Name == vmName && owner.Namespace == vmNamespace {
+			continue
+		}
+		if subnet != nil && !owner.onSubnet(subnet) {
+			continue
+		}
+
+		key := owner.Namespace + "/" + owner.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		conflicts = append(conflicts, owner)
+	}
+
+	return conflicts
+}
+
+// onSubnet checks whether the interface of a MACOwner may be on the logical switch of a subnet
+func (o *MACOwner) onSubnet(subnet *kubeovnv1.Subnet) bool {
+	if o.Subnet != "" {
+		return o.Subnet == subnet.Name
+	}
+
+	for _, ip := range o.IPs {
+		if subnetContainsIPs(subnet, ip) {
+			return true
+		}
+	}
+
+	return len(o.IPs) == 0
+}
+
+// GetLauncherPodAnnotations returns the annotations of the virt-launcher pod running a VMI, which carry the settings
+// Kube-OVN applied to its interfaces. Returns nil if the VMI has no active virt-launcher pod.
+func GetLauncherPodAnnotations(vmiName, vmiNamespace string) (map[string]string, error) {
+	client, err := GetKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	pods, err := client.CoreV1().Pods(vmiNamespace).List(context.Background(), metav1.ListOptions{LabelSelector: LauncherPodSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list virt-launcher pods of namespace %s: %w", vmiNamespace, err)
+	}
+
+	for _, pod := range pods.Items {
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || owner.Kind != v1.VirtualMachineInstanceGroupVersionKind.Kind || owner.Name != vmiName {
+			continue
+		}
+
+		// The pods of a previous run or of a completed migration are kept until they are garbage collected
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		return pod.Annotations, nil
+	}
+
+	return nil, nil
+}
+
 // GetKubeovnAnnotationsForVM returns the Kube-OVN annotations to set on the VM to persist its MAC and IP addresses
 func GetKubeovnAnnotationsForVM(vm *v1.VirtualMachine) (map[string]string, error) {
 	if vm == nil {
 		return nil, fmt.Errorf("VM object is nil")
 	}
-	netInfo, err := GetNetInfoForVm(vm)
+	netInfo, _, err := GetNetInfoForVm(vm, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve netInfo for VM %s/%s: %w", vm.Namespace, vm.Name, err)
 	}
 
+	return NetInfosToAnnotations(netInfo, nil), nil
+}
+
+// GetKubeovnAnnotationsForVMI returns the Kube-OVN annotations to set on a standalone VMI to persist its MAC and IP addresses
+func GetKubeovnAnnotationsForVMI(vmi *v1.VirtualMachineInstance) (map[string]string, error) {
+	if vmi == nil {
+		return nil, fmt.Errorf("VMI object is nil")
+	}
+	netInfo, _, err := GetNetInfoForVMI(vmi, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve netInfo for VMI %s/%s: %w", vmi.Namespace, vmi.Name, err)
+	}
+
+	return NetInfosToAnnotations(netInfo, nil), nil
+}
+
+// NetInfosToAnnotations returns the Kube-OVN annotations persisting the identity of the interfaces of a VM or VMI,
+// as returned by GetNetInfoForVm or GetNetInfoForVMI, along with the settings of the interfaces read from the
+// annotations of its virt-launcher pod, if any
+func NetInfosToAnnotations(netInfos []NetInfo, podAnnotations map[string]string) map[string]string {
 	annotations := make(map[string]string)
 
-	for _, netInf := range netInfo {
-		anns := netInf.ToAnnotations()
-		for k, v := range anns {
+	for _, netInf := range netInfos {
+		netInf.ReadPodSettings(podAnnotations)
+		for k, v := range netInf.ToAnnotations() {
 			annotations[k] = v
 		}
 	}
 
-	return annotations, nil
+	return annotations
+}
+
+// SubnetNamesOfNetInfos returns the sorted names of the Kube-OVN subnets the addresses of the interfaces are
+// allocated from
+func SubnetNamesOfNetInfos(netInfos []NetInfo) []string {
+	subnets := make(map[string]bool)
+	for _, netInfo := range netInfos {
+		subnets[netInfo.Subnet] = true
+	}
+
+	return sortedNames(subnets)
+}
+
+// GetNetInfoForVm returns the identity of the VM's interfaces, along with the Multus networks whose identity isn't
+// persisted.
+// See getNetInfosForNetworks for the sources used when Kube-OVN doesn't hold the IP of an interface anymore.
+func GetNetInfoForVm(vm *v1.VirtualMachine, trustPersisted bool) ([]NetInfo, []SkippedNetwork, error) {
+	// The VMI only exists while the VM runs, it is only retrieved if an interface needs it
+	getVMI := func() (*v1.VirtualMachineInstance, error) {
+		vmi, err := GetVMI(vm.Namespace, vm.Name)
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return vmi, err
+	}
+
+	netInfos, skipped, err := getNetInfosForNetworks(vm.Spec.Template.Spec.Networks, vm.Name, vm.Namespace, getVMI, vm.Spec.Template.ObjectMeta.Annotations, trustPersisted)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve the identity of VM %s/%s: %w", vm.Namespace, vm.Name, err)
+	}
+
+	return netInfos, skipped, nil
 }
 
-// GetNetInfoForVm returns the IPs and NAD annotations of the VM's interfaces
-func GetNetInfoForVm(vm *v1.VirtualMachine) ([]NetInfo, error) {
-	ips, nads, err := GetIPsForVM(vm)
+// GetNetInfoForVMI returns the identity of the VMI's interfaces, along with the Multus networks whose identity isn't
+// persisted.
+// See getNetInfosForNetworks for the sources used when Kube-OVN doesn't hold the IP of an interface anymore.
+func GetNetInfoForVMI(vmi *v1.VirtualMachineInstance, trustPersisted bool) ([]NetInfo, []SkippedNetwork, error) {
+	getVMI := func() (*v1.VirtualMachineInstance, error) {
+		return vmi, nil
+	}
+
+	netInfos, skipped, err := getNetInfosForNetworks(vmi.Spec.Networks, vmi.Name, vmi.Namespace, getVMI, vmi.ObjectMeta.Annotations, trustPersisted)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve IP CRs for VM %s/%s: %w", vm.Namespace, vm.Name, err)
+		return nil, nil, fmt.Errorf("failed to retrieve the identity of VMI %s/%s: %w", vmi.Namespace, vmi.Name, err)
 	}
 
+	return netInfos, skipped, nil
+}
+
+// getNetInfosForNetworks returns the identity of the interfaces attached to the networks of a VM or VMI, along with
+// the Multus networks whose identity isn't persisted. Kube-OVN may not hold the IP of an interface anymore, right after a node failure or
+// once it garbage collected the IP of a stopped VM. The identity of such interfaces is read from the interfaces
+// reported in the status of the VMI, then from the annotations persisted by a previous backup or restore.
+// When trustPersisted is set, the persisted annotations are read first, as the authoritative identity of the interface.
+func getNetInfosForNetworks(networks []v1.Network, vmName, vmNamespace string, getVMI func() (*v1.VirtualMachineInstance, error), annotations map[string]string, trustPersisted bool) ([]NetInfo, []SkippedNetwork, error) {
+	nads, skipped, err := nadAnnotationsOfNetworks(networks, vmName, vmNamespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	persisted := NetInfosFromAnnotations(annotations)
 	var netInfos []NetInfo
-	for i, ip := range ips {
-		netInfos = append(netInfos, *IPToNetInfo(nads[i], ip))
+	var reported []NetInfo
+	vmiFetched := false
+	for _, nadAnnotation := range nads {
+		ip, err := GetIPForVM(nadAnnotation, vmName, vmNamespace)
+		if err == nil {
+			netInfos = append(netInfos, *IPToNetInfo(nadAnnotation, *ip))
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, err
+		}
+
+		var netInfo NetInfo
+		var ok bool
+		if trustPersisted {
+			netInfo, ok = findNetInfo(persisted, nadAnnotation)
+		}
+
+		if !ok && !vmiFetched {
+			vmi, err := getVMI()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to retrieve the VMI of %s/%s: %w", vmNamespace, vmName, err)
+			}
+			if vmi != nil {
+				reported = NetInfosFromVMIStatus(vmi)
+			}
+			vmiFetched = true
+		}
+		if !ok {
+			netInfo, ok = findNetInfo(reported, nadAnnotation)
+			// Only the bridged interfaces report the MAC address Kube-OVN allocated, and none reports its subnet,
+			// the persisted ones are kept instead
+			if persistedInfo, found := findNetInfo(persisted, nadAnnotation); ok && found {
+				if netInfo.MAC == "" {
+					netInfo.MAC = persistedInfo.MAC
+				}
+				if netInfo.Subnet == "" {
+					netInfo.Subnet = persistedInfo.Subnet
+				}
+			}
+			if ok {
+				ips, allocationErr := allocatedIPs(netInfo.IPs, netInfo.Subnet)
+				if allocationErr != nil {
+					return nil, nil, allocationErr
+				}
+				netInfo.IPs = ips
+			}
+		}
+		if !ok {
+			netInfo, ok = findNetInfo(persisted, nadAnnotation)
+		}
+		if !ok || (netInfo.MAC == "" && netInfo.IPs == "") {
+			return nil, nil, fmt.Errorf("no identity found for %s: %w", nadAnnotation, err)
+		}
+
+		netInfos = append(netInfos, netInfo)
 	}
 
-	return netInfos, nil
+	return netInfos, skipped, nil
 }
 
-// GetIPsForVM returns the IPs of the VM's interfaces and the corresponding NAD for each
-func GetIPsForVM(vm *v1.VirtualMachine) ([]kubeovnv1.IP, []string, error) {
+// findNetInfo returns the NetInfo of a NAD annotation among a list of NetInfos
+func findNetInfo(netInfos []NetInfo, nadAnnotation string) (NetInfo, bool) {
+	for _, netInfo := range netInfos {
+		if netInfo.NADAnnotation == nadAnnotation {
+			return netInfo, true
+		}
+	}
+
+	return NetInfo{}, false
+}
+
+// allocatedIPs narrows the comma-separated IP list reported by a guest down to the addresses Kube-OVN may have
+// allocated to its interface: at most one per family, as the guest may also report link-local addresses, SLAAC
+// addresses or secondary addresses of its own. The addresses inside the CIDR of the subnet, if known, are preferred.
+func allocatedIPs(ips, subnetName string) (string, error) {
+	var cidrs []*net.IPNet
+	if subnetName != "" {
+		subnet, err := GetSubnet(subnetName)
+		if err != nil {
+			return "", err
+		}
+		if subnet != nil {
+			for _, block := range strings.Split(subnet.Spec.CIDRBlock, ",") {
+				if _, cidr, err := net.ParseCIDR(block); err == nil {
+					cidrs = append(cidrs, cidr)
+				}
+			}
+		}
+	}
+	inSubnet := func(ip net.IP) bool {
+		for _, cidr := range cidrs {
+			if cidr.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The first address of each family wins, unless a later one belongs to the subnet while it doesn't
+	var ipv4, ipv6 string
+	for _, address := range strings.Split(withoutLinkLocalIPs(ips), ",") {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			continue
+		}
+
+		kept := &ipv6
+		if ip.To4() != nil {
+			kept = &ipv4
+		}
+		if *kept == "" || (inSubnet(ip) && !inSubnet(net.ParseIP(*kept))) {
+			*kept = address
+		}
+	}
+
+	var allocated []string
+	for _, address := range []string{ipv4, ipv6} {
+		if address != "" {
+			allocated = append(allocated, address)
+		}
+	}
+
+	return strings.Join(allocated, ","), nil
+}
+
+// withoutLinkLocalIPs removes the link-local addresses from a comma-separated IP list
+func withoutLinkLocalIPs(ips string) string {
+	var kept []string
+	for _, address := range strings.Split(ips, ",") {
+		ip := net.ParseIP(address)
+		if address == "" || (ip != nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast())) {
+			continue
+		}
+		kept = append(kept, address)
+	}
+
+	return strings.Join(kept, ",")
+}
+
+// NADsOfNetworks returns the NetworkAttachmentDefinitions referenced by the Multus networks of a VM or VMI,
+// in the order of the networks and without duplicates. Bare [NAD] names resolve in the namespace of the VM.
+func NADsOfNetworks(networks []v1.Network, vmNamespace string) ([]types.NamespacedName, error) {
+	seen := make(map[types.NamespacedName]bool)
+	var nads []types.NamespacedName
+	for _, network := range networks {
+		if network.Multus == nil {
+			continue
+		}
+
+		nadName, nadNamespace, err := parseNetworkName(network.Multus.NetworkName, vmNamespace)
+		if err != nil {
+			return nil, err
+		}
+
+		nad := types.NamespacedName{Namespace: nadNamespace, Name: nadName}
+		if !seen[nad] {
+			seen[nad] = true
+			nads = append(nads, nad)
+		}
+	}
+
+	return nads, nil
+}
+
+// nadAnnotationsOfNetworks returns the NAD annotation of every interface of a VM or VMI managed by Kube-OVN, along
+// with the Multus networks that are skipped and why: the ones Kube-OVN doesn't manage, and the ones that only
+// delegate their IPAM to Kube-OVN, which aren't supported.
+func nadAnnotationsOfNetworks(networks []v1.Network, vmName, vmNamespace string) ([]string, []SkippedNetwork, error) {
 	// No network on the VM means it will inherit the default network and only the default network
-	if len(vm.Spec.Template.Spec.Networks) == 0 {
-		ips, err := GetIPsForDefaultNetwork(vm.Name, vm.Namespace)
-		return ips, []string{defaultNetworkAnnotation}, err
+	if len(networks) == 0 {
+		return []string{defaultNetworkAnnotation}, nil, nil
 	}
 
 	multusIsPrimary := false
 	explicitPodNetwork := false
-	var ips []kubeovnv1.IP
 	var nads []string
+	var skipped []SkippedNetwork
 
-	// Pass over every network defined in the specs and extract its IP CustomResource
-	for _, network := range vm.Spec.Template.Spec.Networks {
+	// Pass over every network defined in the specs and extract the NAD annotation of its interface
+	for _, network := range networks {
 		// We're mounting the default network of the cluster on one of the interfaces
 		if network.Pod != nil {
 			explicitPodNetwork = true
-			ip, err := GetIPsForDefaultNetwork(vm.Name, vm.Namespace)
-			if err != nil {
-				return nil, nil, err
-			}
-
-			ips = append(ips, ip...)
 			nads = append(nads, defaultNetworkAnnotation)
 		}
 
@@ -77,32 +568,24 @@ func GetIPsForVM(vm *v1.VirtualMachine) ([]kubeovnv1.IP, []string, error) {
 				multusIsPrimary = true
 			}
 
-			// Convert the Kubevirt NetworkName to a NAD annotation
-			nadAnnotation, err := NetworkNameToNadAnnotation(network.Multus.NetworkName)
-			if err != nil {
-				return nil, nil, fmt.Errorf("invalid network name for vm %s/%s: %w", vm.Namespace, vm.Name, err)
+			// Convert the Kubevirt NetworkName to a NAD annotation, following the provider configured in the NAD
+			nadAnnotation, err := ResolveNadAnnotation(network.Multus.NetworkName, vmNamespace)
+			if errors.Is(err, ErrUnmanagedNetwork) || errors.Is(err, ErrIPAMOnlyNetwork) {
+				skipped = append(skipped, SkippedNetwork{Name: network.Name, Reason: err.Error(), Unsupported: errors.Is(err, ErrIPAMOnlyNetwork)})
+				continue
 			}
-
-			ip, err := GetIPForVM(nadAnnotation, vm.Name, vm.Namespace)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, fmt.Errorf("invalid network name for vm %s/%s: %w", vmNamespace, vmName, err)
 			}
 
-			ips = append(ips, *ip)
 			nads = append(nads, nadAnnotation)
 		}
 	}
 
 	// If no Multus interface is primary, a default interface will be injected
 	if !multusIsPrimary && !explicitPodNetwork {
-		ip, err := GetIPsForDefaultNetwork(vm.Name, vm.Namespace)
-		if err != nil {
-			return nil, nil, err
-		}
-
-		ips = append(ips, ip...)
 		nads = append(nads, defaultNetworkAnnotation)
 	}
 
-	return ips, nads, nil
+	return nads, skipped, nil
 }
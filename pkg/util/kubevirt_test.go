@@ -2,24 +2,48 @@ package util
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
 	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	v1 "kubevirt.io/api/core/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
 )
 
-func TestGetIPsForVM(t *testing.T) {
+func TestGetNetInfoForVmNetworks(t *testing.T) {
+	// Mock GetNetworkClient, the Kube-OVN NADs don't exist and rely on the default provider
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	nadClient := nadfake.NewSimpleClientset()
+	_, _ = nadClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions("test-ns").Create(context.Background(), &nadv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "bridge-nad", Namespace: "test-ns"},
+		Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.1", "type": "bridge", "bridge": "br0"}`},
+	}, metav1.CreateOptions{})
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadClient, nil
+	}
+
 	// Mock GetKubeOvnClient
 	originalGetKubeOvnClient := GetKubeOvnClient
 	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
 
+	// Mock GetVMI, the VMs are stopped
+	originalGetVMI := GetVMI
+	defer func() { GetVMI = originalGetVMI }()
+	GetVMI = func(namespace, name string) (*v1.VirtualMachineInstance, error) {
+		return nil, apierrors.NewNotFound(v1.Resource("virtualmachineinstances"), name)
+	}
+
 	tests := []struct {
 		name        string
 		machine     v1.VirtualMachine
 		existingIPs []*kubeovnv1.IP
-		wantIPNames []string
 		wantNads    []string
 		wantErr     bool
 	}{
@@ -45,9 +69,8 @@ func TestGetIPsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantIPNames: []string{"test-vm.test-ns"},
-			wantNads:    []string{defaultNetworkAnnotation},
-			wantErr:     false,
+			wantNads: []string{defaultNetworkAnnotation},
+			wantErr:  false,
 		},
 		{
 			name: "VM with explicit Pod network",
@@ -78,9 +101,8 @@ func TestGetIPsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantIPNames: []string{"test-vm.test-ns"},
-			wantNads:    []string{defaultNetworkAnnotation},
-			wantErr:     false,
+			wantNads: []string{defaultNetworkAnnotation},
+			wantErr:  false,
 		},
 		{
 			name: "VM with Multus network (secondary)",
@@ -118,9 +140,86 @@ func TestGetIPsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantIPNames: []string{"test-vm.test-ns.test-nad.test-ns.ovn", "test-vm.test-ns"},
-			wantNads:    []string{"test-nad.test-ns.ovn.kubernetes.io", defaultNetworkAnnotation},
-			wantErr:     false,
+			wantNads: []string{"test-nad.test-ns.ovn.kubernetes.io", defaultNetworkAnnotation},
+			wantErr:  false,
+		},
+		{
+			name: "VM with a bare Multus network name",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{
+								{
+									Name: "secondary",
+									NetworkSource: v1.NetworkSource{
+										Multus: &v1.MultusNetwork{
+											NetworkName: "test-nad",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns.test-nad.test-ns.ovn",
+					},
+				},
+			},
+			wantNads: []string{"test-nad.test-ns.ovn.kubernetes.io", defaultNetworkAnnotation},
+			wantErr:  false,
+		},
+		{
+			name: "VM with a Multus network of another namespace",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{
+								{
+									Name: "secondary",
+									NetworkSource: v1.NetworkSource{
+										Multus: &v1.MultusNetwork{
+											NetworkName: "networks/shared-nad",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns.shared-nad.networks.ovn",
+					},
+				},
+			},
+			wantNads: []string{"shared-nad.networks.ovn.kubernetes.io", defaultNetworkAnnotation},
+			wantErr:  false,
 		},
 		{
 			name: "VM with Multus network as default (primary)",
@@ -154,9 +253,8 @@ func TestGetIPsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantIPNames: []string{"test-vm.test-ns.test-nad.test-ns.ovn"},
-			wantNads:    []string{"test-nad.test-ns.ovn.kubernetes.io"},
-			wantErr:     false,
+			wantNads: []string{"test-nad.test-ns.ovn.kubernetes.io"},
+			wantErr:  false,
 		},
 		{
 			name: "VM with multiple secondary networks",
@@ -207,7 +305,6 @@ func TestGetIPsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantIPNames: []string{"test-vm.test-ns.nad1.test-ns.ovn", "test-vm.test-ns.nad2.test-ns.ovn", "test-vm.test-ns"},
 			wantNads: []string{
 				"nad1.test-ns.ovn.kubernetes.io",
 				"nad2.test-ns.ovn.kubernetes.io",
@@ -247,9 +344,8 @@ func TestGetIPsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantIPNames: []string{"test-vm.test-ns.nad-primary.test-ns.ovn"},
-			wantNads:    []string{"nad-primary.test-ns.ovn.kubernetes.io"},
-			wantErr:     false,
+			wantNads: []string{"nad-primary.test-ns.ovn.kubernetes.io"},
+			wantErr:  false,
 		},
 		{
 			name: "VM with non-explicit default network + a multus secondary",
@@ -287,9 +383,48 @@ func TestGetIPsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantIPNames: []string{"test-vm.test-ns.nad-secondary.test-ns.ovn", "test-vm.test-ns"},
-			wantNads:    []string{"nad-secondary.test-ns.ovn.kubernetes.io", defaultNetworkAnnotation},
-			wantErr:     false,
+			wantNads: []string{"nad-secondary.test-ns.ovn.kubernetes.io", defaultNetworkAnnotation},
+			wantErr:  false,
+		},
+		{
+			name: "VM with a Multus network not managed by Kube-OVN",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{
+								{
+									Name: "default",
+									NetworkSource: v1.NetworkSource{
+										Pod: &v1.PodNetwork{},
+									},
+								},
+								{
+									Name: "bridge",
+									NetworkSource: v1.NetworkSource{
+										Multus: &v1.MultusNetwork{
+											NetworkName: "test-ns/bridge-nad",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+				},
+			},
+			wantNads: []string{defaultNetworkAnnotation},
+			wantErr:  false,
 		},
 		{
 			name: "Error handling for invalid Multus network name",
@@ -306,7 +441,7 @@ func TestGetIPsForVM(t *testing.T) {
 									Name: "invalid",
 									NetworkSource: v1.NetworkSource{
 										Multus: &v1.MultusNetwork{
-											NetworkName: "invalid-name",
+											NetworkName: "test-ns/test-nad/extra",
 										},
 									},
 								},
@@ -348,27 +483,23 @@ func TestGetIPsForVM(t *testing.T) {
 				return fakeClient, nil
 			}
 
-			got, nads, err := GetIPsForVM(&tt.machine)
+			got, _, err := GetNetInfoForVm(&tt.machine, false)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("GetIPsForVM() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetNetInfoForVm() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			if !tt.wantErr {
-				if len(got) != len(tt.wantIPNames) {
-					t.Errorf("GetIPsForVM() got %d IPs, want %d", len(got), len(tt.wantIPNames))
-					return
-				}
-				if len(nads) != len(tt.wantNads) {
-					t.Errorf("GetIPsForVM() got %d NADs, want %d", len(nads), len(tt.wantNads))
+				if len(got) != len(tt.wantNads) {
+					t.Errorf("GetNetInfoForVm() got %d NetInfos, want %d", len(got), len(tt.wantNads))
 					return
 				}
-				for i, name := range tt.wantIPNames {
-					if got[i].Name != name {
-						t.Errorf("GetIPsForVM() got IP[%d].Name = %v, want %v", i, got[i].Name, name)
+				for i, nad := range tt.wantNads {
+					if got[i].NADAnnotation != nad {
+						t.Errorf("GetNetInfoForVm() got[%d].NADAnnotation = %v, want %v", i, got[i].NADAnnotation, nad)
 					}
-					if nads[i] != tt.wantNads[i] {
-						t.Errorf("GetIPsForVM() got nads[%d] = %v, want %v", i, nads[i], tt.wantNads[i])
+					if got[i].Source != NetInfoSourceIP {
+						t.Errorf("GetNetInfoForVm() got[%d].Source = %v, want %v", i, got[i].Source, NetInfoSourceIP)
 					}
 				}
 			}
@@ -377,16 +508,30 @@ func TestGetIPsForVM(t *testing.T) {
 }
 
 func TestGetNetInfoForVm(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadfake.NewSimpleClientset(), nil
+	}
+
 	// Mock GetKubeOvnClient
 	originalGetKubeOvnClient := GetKubeOvnClient
 	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
 
+	// Mock GetVMI
+	originalGetVMI := GetVMI
+	defer func() { GetVMI = originalGetVMI }()
+
 	tests := []struct {
-		name         string
-		machine      v1.VirtualMachine
-		existingIPs  []*kubeovnv1.IP
-		wantNetInfos []NetInfo
-		wantErr      bool
+		name        string
+		machine     v1.VirtualMachine
+		existingIPs []*kubeovnv1.IP
+		vmi         *v1.VirtualMachineInstance
+		// trustPersisted reads the persisted annotations before the interfaces reported by the VMI
+		trustPersisted bool
+		wantNetInfos   []NetInfo
+		wantErr        bool
 	}{
 		{
 			name: "VM with default network only",
@@ -419,6 +564,7 @@ func TestGetNetInfoForVm(t *testing.T) {
 					NADAnnotation: defaultNetworkAnnotation,
 					IPs:           "10.0.0.1",
 					MAC:           "00:00:00:00:00:01",
+					Source:        NetInfoSourceIP,
 				},
 			},
 			wantErr: false,
@@ -472,17 +618,19 @@ func TestGetNetInfoForVm(t *testing.T) {
 					NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
 					IPs:           "192.168.1.1",
 					MAC:           "00:00:00:00:00:02",
+					Source:        NetInfoSourceIP,
 				},
 				{
 					NADAnnotation: defaultNetworkAnnotation,
 					IPs:           "10.0.0.1",
 					MAC:           "00:00:00:00:00:01",
+					Source:        NetInfoSourceIP,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "Error handling when IP retrieval fails",
+			name: "Missing IP falls back to the interfaces reported by the VMI",
 			machine: v1.VirtualMachine{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "test-vm",
@@ -490,79 +638,29 @@ func TestGetNetInfoForVm(t *testing.T) {
 				},
 				Spec: v1.VirtualMachineSpec{
 					Template: &v1.VirtualMachineInstanceTemplateSpec{
-						Spec: v1.VirtualMachineInstanceSpec{
-							Networks: []v1.Network{},
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:09",
+								"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "192.168.1.9",
+							},
 						},
-					},
-				},
-			},
-			existingIPs: []*kubeovnv1.IP{}, // Missing IP
-			wantErr:     true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			fakeClient := fake.NewSimpleClientset()
-			for _, ip := range tt.existingIPs {
-				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
-			}
-
-			GetKubeOvnClient = func() (KubeOvnClient, error) {
-				return fakeClient, nil
-			}
-
-			got, err := GetNetInfoForVm(&tt.machine)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetNetInfoForVm() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-
-			if !tt.wantErr {
-				if len(got) != len(tt.wantNetInfos) {
-					t.Errorf("GetNetInfoForVm() got %d NetInfos, want %d", len(got), len(tt.wantNetInfos))
-					return
-				}
-				for i, want := range tt.wantNetInfos {
-					if got[i].NADAnnotation != want.NADAnnotation {
-						t.Errorf("GetNetInfoForVm() got[%d].NADAnnotation = %v, want %v", i, got[i].NADAnnotation, want.NADAnnotation)
-					}
-					if got[i].IPs != want.IPs {
-						t.Errorf("GetNetInfoForVm() got[%d].IPs = %v, want %v", i, got[i].IPs, want.IPs)
-					}
-					if got[i].MAC != want.MAC {
-						t.Errorf("GetNetInfoForVm() got[%d].MAC = %v, want %v", i, got[i].MAC, want.MAC)
-					}
-				}
-			}
-		})
-	}
-}
-
-func TestGetKubeovnAnnotationsForVM(t *testing.T) {
-	// Mock GetKubeOvnClient
-	originalGetKubeOvnClient := GetKubeOvnClient
-	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
-
-	tests := []struct {
-		name        string
-		machine     v1.VirtualMachine
-		existingIPs []*kubeovnv1.IP
-		wantAnns    map[string]string
-		useNil      bool
-		wantErr     bool
-	}{
-		{
-			name: "VM with default network only",
-			machine: v1.VirtualMachine{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "test-vm",
-					Namespace: "test-ns",
-				},
-				Spec: v1.VirtualMachineSpec{
-					Template: &v1.VirtualMachineInstanceTemplateSpec{
 						Spec: v1.VirtualMachineInstanceSpec{
-							Networks: []v1.Network{},
+							Networks: []v1.Network{
+								{
+									Name: "default",
+									NetworkSource: v1.NetworkSource{
+										Pod: &v1.PodNetwork{},
+									},
+								},
+								{
+									Name: "secondary",
+									NetworkSource: v1.NetworkSource{
+										Multus: &v1.MultusNetwork{
+											NetworkName: "test-ns/test-nad",
+										},
+									},
+								},
+							},
 						},
 					},
 				},
@@ -578,14 +676,46 @@ func TestGetKubeovnAnnotationsForVM(t *testing.T) {
 					},
 				},
 			},
-			wantAnns: map[string]string{
-				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
-				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			vmi: &v1.VirtualMachineInstance{
+				Spec: v1.VirtualMachineInstanceSpec{
+					Domain: v1.DomainSpec{
+						Devices: v1.Devices{
+							Interfaces: []v1.Interface{
+								{Name: "default", InterfaceBindingMethod: v1.InterfaceBindingMethod{Bridge: &v1.InterfaceBridge{}}},
+								{Name: "secondary", InterfaceBindingMethod: v1.InterfaceBindingMethod{Bridge: &v1.InterfaceBridge{}}},
+							},
+						},
+					},
+					Networks: []v1.Network{
+						{Name: "default", NetworkSource: v1.NetworkSource{Pod: &v1.PodNetwork{}}},
+						{Name: "secondary", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "test-ns/test-nad"}}},
+					},
+				},
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "default", MAC: "00:00:00:00:00:01", IPs: []string{"10.0.0.1"}},
+						{Name: "secondary", MAC: "00:00:00:00:00:02", IPs: []string{"192.168.1.1", "fe80::2"}},
+					},
+				},
+			},
+			wantNetInfos: []NetInfo{
+				{
+					NADAnnotation: defaultNetworkAnnotation,
+					IPs:           "10.0.0.1",
+					MAC:           "00:00:00:00:00:01",
+					Source:        NetInfoSourceIP,
+				},
+				{
+					NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io",
+					IPs:           "192.168.1.1",
+					MAC:           "00:00:00:00:00:02",
+					Source:        NetInfoSourceVMIStatus,
+				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "VM with multiple networks",
+			name: "Missing IP of a masquerade interface keeps the persisted MAC address and subnet",
 			machine: v1.VirtualMachine{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "test-vm",
@@ -593,14 +723,19 @@ func TestGetKubeovnAnnotationsForVM(t *testing.T) {
 				},
 				Spec: v1.VirtualMachineSpec{
 					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:09",
+								"ovn.kubernetes.io/ip_address":     "10.0.0.9",
+								"ovn.kubernetes.io/logical_switch": "ovn-default",
+							},
+						},
 						Spec: v1.VirtualMachineInstanceSpec{
 							Networks: []v1.Network{
 								{
-									Name: "secondary",
+									Name: "default",
 									NetworkSource: v1.NetworkSource{
-										Multus: &v1.MultusNetwork{
-											NetworkName: "test-ns/test-nad",
-										},
+										Pod: &v1.PodNetwork{},
 									},
 								},
 							},
@@ -608,30 +743,338 @@ func TestGetKubeovnAnnotationsForVM(t *testing.T) {
 					},
 				},
 			},
-			existingIPs: []*kubeovnv1.IP{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test-vm.test-ns",
+			vmi: &v1.VirtualMachineInstance{
+				Spec: v1.VirtualMachineInstanceSpec{
+					Domain: v1.DomainSpec{
+						Devices: v1.Devices{
+							Interfaces: []v1.Interface{
+								{Name: "default", InterfaceBindingMethod: v1.InterfaceBindingMethod{Masquerade: &v1.InterfaceMasquerade{}}},
+							},
+						},
 					},
-					Spec: kubeovnv1.IPSpec{
-						V4IPAddress: "10.0.0.1",
-						MacAddress:  "00:00:00:00:00:01",
+					Networks: []v1.Network{
+						{Name: "default", NetworkSource: v1.NetworkSource{Pod: &v1.PodNetwork{}}},
 					},
 				},
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test-vm.test-ns.test-nad.test-ns.ovn",
-					},
-					Spec: kubeovnv1.IPSpec{
-						V4IPAddress: "10.0.0.2",
-						MacAddress:  "00:00:00:00:00:02",
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "default", MAC: "02:00:00:00:00:01", IPs: []string{"10.0.0.1"}},
 					},
 				},
 			},
-			wantAnns: map[string]string{
-				"ovn.kubernetes.io/ip_address":                   "10.0.0.1",
-				"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
-				"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.2",
+			wantNetInfos: []NetInfo{
+				{
+					NADAnnotation: defaultNetworkAnnotation,
+					IPs:           "10.0.0.1",
+					MAC:           "00:00:00:00:00:09",
+					Subnet:        "ovn-default",
+					Source:        NetInfoSourceVMIStatus,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing IP keeps a single address per family among the ones reported by the guest",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:01",
+								"ovn.kubernetes.io/ip_address":     "10.0.0.7",
+								"ovn.kubernetes.io/logical_switch": "ovn-default",
+							},
+						},
+					},
+				},
+			},
+			vmi: &v1.VirtualMachineInstance{
+				Spec: v1.VirtualMachineInstanceSpec{
+					Domain: v1.DomainSpec{
+						Devices: v1.Devices{
+							Interfaces: []v1.Interface{
+								{Name: "default", InterfaceBindingMethod: v1.InterfaceBindingMethod{Bridge: &v1.InterfaceBridge{}}},
+							},
+						},
+					},
+					Networks: []v1.Network{
+						{Name: "default", NetworkSource: v1.NetworkSource{Pod: &v1.PodNetwork{}}},
+					},
+				},
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "default", MAC: "00:00:00:00:00:01", IPs: []string{"192.168.122.5", "10.0.0.7", "fe80::1", "2001:db8::5", "2001:db8::6"}},
+					},
+				},
+			},
+			wantNetInfos: []NetInfo{
+				{
+					NADAnnotation: defaultNetworkAnnotation,
+					IPs:           "10.0.0.7,2001:db8::5",
+					MAC:           "00:00:00:00:00:01",
+					Subnet:        "ovn-default",
+					Source:        NetInfoSourceVMIStatus,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing IP reads the persisted annotations first when they are trusted",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"ovn.kubernetes.io/mac_address": "00:00:00:00:00:09",
+								"ovn.kubernetes.io/ip_address":  "10.0.0.9",
+							},
+						},
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{},
+						},
+					},
+				},
+			},
+			vmi: &v1.VirtualMachineInstance{
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "default", MAC: "00:00:00:00:00:01", IPs: []string{"10.0.0.1"}},
+					},
+				},
+			},
+			trustPersisted: true,
+			wantNetInfos: []NetInfo{
+				{
+					NADAnnotation: defaultNetworkAnnotation,
+					IPs:           "10.0.0.9",
+					MAC:           "00:00:00:00:00:09",
+					Source:        NetInfoSourceAnnotations,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing IP of a stopped VM falls back to the persisted annotations",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"ovn.kubernetes.io/mac_address":    "00:00:00:00:00:01",
+								"ovn.kubernetes.io/ip_address":     "10.0.0.1",
+								"ovn.kubernetes.io/logical_switch": "ovn-default",
+							},
+						},
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{},
+						},
+					},
+				},
+			},
+			wantNetInfos: []NetInfo{
+				{
+					NADAnnotation: defaultNetworkAnnotation,
+					IPs:           "10.0.0.1",
+					MAC:           "00:00:00:00:00:01",
+					Subnet:        "ovn-default",
+					Source:        NetInfoSourceAnnotations,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error handling when IP retrieval fails",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{},
+						},
+					},
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{}, // Missing IP
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), &kubeovnv1.Subnet{
+				ObjectMeta: metav1.ObjectMeta{Name: "ovn-default"},
+				Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "10.0.0.0/24"},
+			}, metav1.CreateOptions{})
+
+			GetKubeOvnClient = func() (KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+			GetVMI = func(namespace, name string) (*v1.VirtualMachineInstance, error) {
+				if tt.vmi == nil {
+					return nil, apierrors.NewNotFound(v1.Resource("virtualmachineinstances"), name)
+				}
+				return tt.vmi, nil
+			}
+
+			got, _, err := GetNetInfoForVm(&tt.machine, tt.trustPersisted)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetNetInfoForVm() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				if len(got) != len(tt.wantNetInfos) {
+					t.Errorf("GetNetInfoForVm() got %d NetInfos, want %d", len(got), len(tt.wantNetInfos))
+					return
+				}
+				for i, want := range tt.wantNetInfos {
+					if got[i].NADAnnotation != want.NADAnnotation {
+						t.Errorf("GetNetInfoForVm() got[%d].NADAnnotation = %v, want %v", i, got[i].NADAnnotation, want.NADAnnotation)
+					}
+					if got[i].IPs != want.IPs {
+						t.Errorf("GetNetInfoForVm() got[%d].IPs = %v, want %v", i, got[i].IPs, want.IPs)
+					}
+					if got[i].MAC != want.MAC {
+						t.Errorf("GetNetInfoForVm() got[%d].MAC = %v, want %v", i, got[i].MAC, want.MAC)
+					}
+					if got[i].Subnet != want.Subnet {
+						t.Errorf("GetNetInfoForVm() got[%d].Subnet = %v, want %v", i, got[i].Subnet, want.Subnet)
+					}
+					if got[i].Source != want.Source {
+						t.Errorf("GetNetInfoForVm() got[%d].Source = %v, want %v", i, got[i].Source, want.Source)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGetKubeovnAnnotationsForVM(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadfake.NewSimpleClientset(), nil
+	}
+
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	// Mock GetVMI, the VMs are stopped
+	originalGetVMI := GetVMI
+	defer func() { GetVMI = originalGetVMI }()
+	GetVMI = func(namespace, name string) (*v1.VirtualMachineInstance, error) {
+		return nil, apierrors.NewNotFound(v1.Resource("virtualmachineinstances"), name)
+	}
+
+	tests := []struct {
+		name        string
+		machine     v1.VirtualMachine
+		existingIPs []*kubeovnv1.IP
+		wantAnns    map[string]string
+		useNil      bool
+		wantErr     bool
+	}{
+		{
+			name: "VM with default network only",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{},
+						},
+					},
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: kubeovnv1.IPSpec{
+						V4IPAddress: "10.0.0.1",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+			},
+			wantAnns: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantErr: false,
+		},
+		{
+			name: "VM with multiple networks",
+			machine: v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						Spec: v1.VirtualMachineInstanceSpec{
+							Networks: []v1.Network{
+								{
+									Name: "secondary",
+									NetworkSource: v1.NetworkSource{
+										Multus: &v1.MultusNetwork{
+											NetworkName: "test-ns/test-nad",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns",
+					},
+					Spec: kubeovnv1.IPSpec{
+						V4IPAddress: "10.0.0.1",
+						MacAddress:  "00:00:00:00:00:01",
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-vm.test-ns.test-nad.test-ns.ovn",
+					},
+					Spec: kubeovnv1.IPSpec{
+						V4IPAddress: "10.0.0.2",
+						MacAddress:  "00:00:00:00:00:02",
+					},
+				},
+			},
+			wantAnns: map[string]string{
+				"ovn.kubernetes.io/ip_address":                   "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                  "00:00:00:00:00:01",
+				"test-nad.test-ns.ovn.kubernetes.io/ip_address":  "10.0.0.2",
 				"test-nad.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:02",
 			},
 			wantErr: false,
@@ -696,3 +1139,610 @@ func TestGetKubeovnAnnotationsForVM(t *testing.T) {
 		})
 	}
 }
+
+func TestGetKubeovnAnnotationsForVMI(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadfake.NewSimpleClientset(), nil
+	}
+
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	tests := []struct {
+		name        string
+		instance    v1.VirtualMachineInstance
+		existingIPs []*kubeovnv1.IP
+		wantAnns    map[string]string
+		useNil      bool
+		wantErr     bool
+	}{
+		{
+			name: "VMI with default network only",
+			instance: v1.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vmi",
+					Namespace: "test-ns",
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-vmi.test-ns"},
+					Spec:       kubeovnv1.IPSpec{V4IPAddress: "10.0.0.1", MacAddress: "00:00:00:00:00:01"},
+				},
+			},
+			wantAnns: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			wantErr: false,
+		},
+		{
+			name: "VMI with a primary Multus network",
+			instance: v1.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vmi",
+					Namespace: "test-ns",
+				},
+				Spec: v1.VirtualMachineInstanceSpec{
+					Networks: []v1.Network{
+						{
+							Name: "primary",
+							NetworkSource: v1.NetworkSource{
+								Multus: &v1.MultusNetwork{NetworkName: "test-ns/nad1", Default: true},
+							},
+						},
+					},
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-vmi.test-ns.nad1.test-ns.ovn"},
+					Spec:       kubeovnv1.IPSpec{V4IPAddress: "10.0.1.1", V6IPAddress: "fd00::1", MacAddress: "00:00:00:00:00:11"},
+				},
+			},
+			wantAnns: map[string]string{
+				"nad1.test-ns.ovn.kubernetes.io/ip_address":  "10.0.1.1,fd00::1",
+				"nad1.test-ns.ovn.kubernetes.io/mac_address": "00:00:00:00:00:11",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error propagation from GetNetInfoForVMI",
+			instance: v1.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vmi",
+					Namespace: "test-ns",
+				},
+			},
+			existingIPs: []*kubeovnv1.IP{},
+			wantErr:     true,
+		},
+		{
+			name:    "Nil VMI object",
+			useNil:  true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+
+			GetKubeOvnClient = func() (KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			var vmi *v1.VirtualMachineInstance
+			if !tt.useNil {
+				vmi = &tt.instance
+			}
+
+			got, err := GetKubeovnAnnotationsForVMI(vmi)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetKubeovnAnnotationsForVMI() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				if len(got) != len(tt.wantAnns) {
+					t.Errorf("GetKubeovnAnnotationsForVMI() got %d annotations, want %d", len(got), len(tt.wantAnns))
+				}
+				for k, v := range tt.wantAnns {
+					if got[k] != v {
+						t.Errorf("GetKubeovnAnnotationsForVMI() annotation[%s] = %v, want %v", k, got[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBuildMACIndex(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	// Mock ListVMIs
+	originalListVMIs := ListVMIs
+	defer func() { ListVMIs = originalListVMIs }()
+
+	existingIPs := []*kubeovnv1.IP{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-a.test-ns"},
+			Spec: kubeovnv1.IPSpec{
+				PodName:     "pod-a",
+				Namespace:   "test-ns",
+				Subnet:      "ovn-default",
+				V4IPAddress: "10.16.0.2",
+				MacAddress:  "00:00:00:00:00:0A",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-b.test-ns"},
+			Spec: kubeovnv1.IPSpec{
+				PodName:     "pod-b",
+				Namespace:   "test-ns",
+				Subnet:      "other-subnet",
+				V4IPAddress: "10.17.0.2",
+				MacAddress:  "00:00:00:00:00:0b",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns"},
+			Spec: kubeovnv1.IPSpec{
+				PodName:     "test-vm",
+				Namespace:   "test-ns",
+				Subnet:      "ovn-default",
+				V4IPAddress: "10.16.0.3",
+				MacAddress:  "00:00:00:00:00:0c",
+			},
+		},
+	}
+
+	existingVMIs := []v1.VirtualMachineInstance{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "vmi-a", Namespace: "test-ns"},
+			Status: v1.VirtualMachineInstanceStatus{
+				Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+					{Name: "default", MAC: "00:00:00:00:00:0d", IPs: []string{"10.16.0.4"}},
+					{Name: "bridge", MAC: "00:00:00:00:00:0e"},
+					{Name: "invalid", MAC: "invalid"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "vmi-b", Namespace: "test-ns"},
+			Status: v1.VirtualMachineInstanceStatus{
+				Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+					{Name: "default", MAC: "00:00:00:00:00:0f", IPs: []string{"10.17.0.4"}},
+				},
+			},
+		},
+	}
+
+	subnet := &kubeovnv1.Subnet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ovn-default"},
+		Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "10.16.0.0/16"},
+	}
+
+	tests := []struct {
+		name          string
+		mac           string
+		subnet        *kubeovnv1.Subnet
+		wantConflicts []string
+	}{
+		{
+			name:          "MAC address of an IP on the same subnet, in another case",
+			mac:           "00:00:00:00:00:0a",
+			subnet:        subnet,
+			wantConflicts: []string{"test-ns/pod-a"},
+		},
+		{
+			name:          "MAC address of an IP on another subnet",
+			mac:           "00:00:00:00:00:0b",
+			subnet:        subnet,
+			wantConflicts: nil,
+		},
+		{
+			name:          "MAC address of an IP on another subnet, unknown subnet",
+			mac:           "00:00:00:00:00:0b",
+			subnet:        nil,
+			wantConflicts: []string{"test-ns/pod-b"},
+		},
+		{
+			name:          "MAC address of the VM itself",
+			mac:           "00:00:00:00:00:0c",
+			subnet:        subnet,
+			wantConflicts: nil,
+		},
+		{
+			name:          "MAC address of a VMI interface on the same subnet",
+			mac:           "00:00:00:00:00:0d",
+			subnet:        subnet,
+			wantConflicts: []string{"test-ns/vmi-a"},
+		},
+		{
+			name:          "MAC address of a VMI interface without IPs",
+			mac:           "00:00:00:00:00:0e",
+			subnet:        subnet,
+			wantConflicts: []string{"test-ns/vmi-a"},
+		},
+		{
+			name:          "MAC address of a VMI interface on another subnet",
+			mac:           "00:00:00:00:00:0f",
+			subnet:        subnet,
+			wantConflicts: nil,
+		},
+		{
+			name:          "unused MAC address",
+			mac:           "00:00:00:00:00:10",
+			subnet:        subnet,
+			wantConflicts: nil,
+		},
+	}
+
+	fakeClient := fake.NewSimpleClientset()
+	for _, ip := range existingIPs {
+		_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+	}
+	GetKubeOvnClient = func() (KubeOvnClient, error) {
+		return fakeClient, nil
+	}
+	ListVMIs = func() ([]v1.VirtualMachineInstance, error) {
+		return existingVMIs, nil
+	}
+
+	index, err := BuildMACIndex()
+	if err != nil {
+		t.Fatalf("BuildMACIndex() unexpected error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := index.Conflicts(tt.mac, tt.subnet, "test-vm", "test-ns")
+			if len(got) != len(tt.wantConflicts) {
+				t.Fatalf("Conflicts() got %d conflicts, want %d", len(got), len(tt.wantConflicts))
+			}
+			for i, want := range tt.wantConflicts {
+				if owner := got[i].Namespace + "/" + got[i].Name; owner != want {
+					t.Errorf("Conflicts() got conflict %s, want %s", owner, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNetInfosFromVMIStatus(t *testing.T) {
+	// Mock GetNetworkClient, the NADs don't exist and rely on the default provider
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadfake.NewSimpleClientset(), nil
+	}
+
+	tests := []struct {
+		name string
+		vmi  v1.VirtualMachineInstance
+		want []NetInfo
+	}{
+		{
+			name: "VMI without networks",
+			vmi: v1.VirtualMachineInstance{
+				Spec: v1.VirtualMachineInstanceSpec{
+					Domain: v1.DomainSpec{
+						Devices: v1.Devices{
+							Interfaces: []v1.Interface{
+								{Name: "default", InterfaceBindingMethod: v1.InterfaceBindingMethod{Bridge: &v1.InterfaceBridge{}}},
+							},
+						},
+					},
+				},
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "default", MAC: "00:00:00:00:00:01", IPs: []string{"10.0.0.1", "fd00::1"}},
+					},
+				},
+			},
+			want: []NetInfo{
+				{NADAnnotation: "ovn.kubernetes.io", MAC: "00:00:00:00:00:01", IPs: "10.0.0.1,fd00::1", Source: NetInfoSourceVMIStatus},
+			},
+		},
+		{
+			name: "VMI with pod and Multus networks",
+			vmi: v1.VirtualMachineInstance{
+				Spec: v1.VirtualMachineInstanceSpec{
+					Domain: v1.DomainSpec{
+						Devices: v1.Devices{
+							Interfaces: []v1.Interface{
+								{Name: "pod", InterfaceBindingMethod: v1.InterfaceBindingMethod{Bridge: &v1.InterfaceBridge{}}},
+								{Name: "secondary", InterfaceBindingMethod: v1.InterfaceBindingMethod{Bridge: &v1.InterfaceBridge{}}},
+							},
+						},
+					},
+					Networks: []v1.Network{
+						{Name: "pod", NetworkSource: v1.NetworkSource{Pod: &v1.PodNetwork{}}},
+						{Name: "secondary", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "test-ns/test-nad"}}},
+					},
+				},
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "pod", MAC: "00:00:00:00:00:01", IP: "10.0.0.1"},
+						{Name: "secondary", MAC: "00:00:00:00:00:02", IPs: []string{"10.0.1.1"}},
+						{Name: "unknown", MAC: "00:00:00:00:00:03"},
+					},
+				},
+			},
+			want: []NetInfo{
+				{NADAnnotation: "ovn.kubernetes.io", MAC: "00:00:00:00:00:01", IPs: "10.0.0.1", Source: NetInfoSourceVMIStatus},
+				{NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io", MAC: "00:00:00:00:00:02", IPs: "10.0.1.1", Source: NetInfoSourceVMIStatus},
+			},
+		},
+		{
+			name: "VMI with a masquerade interface",
+			vmi: v1.VirtualMachineInstance{
+				Spec: v1.VirtualMachineInstanceSpec{
+					Domain: v1.DomainSpec{
+						Devices: v1.Devices{
+							Interfaces: []v1.Interface{
+								{Name: "default", InterfaceBindingMethod: v1.InterfaceBindingMethod{Masquerade: &v1.InterfaceMasquerade{}}},
+							},
+						},
+					},
+				},
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "default", MAC: "02:00:00:00:00:01", IPs: []string{"10.0.0.1"}},
+					},
+				},
+			},
+			want: []NetInfo{
+				{NADAnnotation: "ovn.kubernetes.io", IPs: "10.0.0.1", Source: NetInfoSourceVMIStatus},
+			},
+		},
+		{
+			name: "VMI with an invalid Multus network name",
+			vmi: v1.VirtualMachineInstance{
+				Spec: v1.VirtualMachineInstanceSpec{
+					Networks: []v1.Network{
+						{Name: "secondary", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "a/b/c"}}},
+					},
+				},
+				Status: v1.VirtualMachineInstanceStatus{
+					Interfaces: []v1.VirtualMachineInstanceNetworkInterface{
+						{Name: "secondary", MAC: "00:00:00:00:00:02"},
+					},
+				},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NetInfosFromVMIStatus(&tt.vmi)
+			if len(got) != len(tt.want) {
+				t.Fatalf("NetInfosFromVMIStatus() got %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("NetInfosFromVMIStatus() got[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSubnetNamesOfNetInfos(t *testing.T) {
+	tests := []struct {
+		name     string
+		netInfos []NetInfo
+		want     []string
+	}{
+		{
+			name: "interfaces of several subnets",
+			netInfos: []NetInfo{
+				{Subnet: "ovn-default"},
+				{Subnet: "secondary-subnet"},
+				{Subnet: "ovn-default"},
+				{Subnet: "another-subnet"},
+			},
+			want: []string{"another-subnet", "ovn-default", "secondary-subnet"},
+		},
+		{
+			name: "interface without subnet",
+			netInfos: []NetInfo{
+				{},
+			},
+			want: nil,
+		},
+		{
+			name:     "no interfaces",
+			netInfos: nil,
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SubnetNamesOfNetInfos(tt.netInfos)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SubnetNamesOfNetInfos() got = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("SubnetNamesOfNetInfos() got = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNADsOfNetworks(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks []v1.Network
+		want     []types.NamespacedName
+		wantErr  bool
+	}{
+		{
+			name: "pod and Multus networks",
+			networks: []v1.Network{
+				{Name: "pod", NetworkSource: v1.NetworkSource{Pod: &v1.PodNetwork{}}},
+				{Name: "secondary1", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "test-ns/nad2"}}},
+				{Name: "secondary2", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "other-ns/nad1"}}},
+				{Name: "secondary3", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "test-ns/nad2"}}},
+				{Name: "secondary4", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "nad3"}}},
+				{Name: "secondary5", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "nad2"}}},
+			},
+			want: []types.NamespacedName{
+				{Namespace: "test-ns", Name: "nad2"},
+				{Namespace: "other-ns", Name: "nad1"},
+				{Namespace: "test-ns", Name: "nad3"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "pod network only",
+			networks: []v1.Network{
+				{Name: "pod", NetworkSource: v1.NetworkSource{Pod: &v1.PodNetwork{}}},
+			},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "invalid network name",
+			networks: []v1.Network{
+				{Name: "secondary", NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: "a/b/c"}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NADsOfNetworks(tt.networks, "test-ns")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NADsOfNetworks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NADsOfNetworks() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocatedIPs(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	fakeClient := fake.NewSimpleClientset()
+	_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), &kubeovnv1.Subnet{
+		ObjectMeta: metav1.ObjectMeta{Name: "dual-stack"},
+		Spec:       kubeovnv1.SubnetSpec{CIDRBlock: "10.0.0.0/24,fd00:10::/64"},
+	}, metav1.CreateOptions{})
+	GetKubeOvnClient = func() (KubeOvnClient, error) {
+		return fakeClient, nil
+	}
+
+	tests := []struct {
+		name   string
+		ips    string
+		subnet string
+		want   string
+	}{
+		{
+			name:   "single address per family is kept",
+			ips:    "10.0.0.1,fd00:10::1",
+			subnet: "dual-stack",
+			want:   "10.0.0.1,fd00:10::1",
+		},
+		{
+			name:   "addresses of the subnet are preferred",
+			ips:    "192.168.0.1,2001:db8::1,10.0.0.1,fd00:10::1",
+			subnet: "dual-stack",
+			want:   "10.0.0.1,fd00:10::1",
+		},
+		{
+			name:   "first address of each family without subnet",
+			ips:    "fd00:10::1,10.0.0.1,10.0.0.2,fd00:10::2",
+			subnet: "",
+			want:   "10.0.0.1,fd00:10::1",
+		},
+		{
+			name:   "first address of each family with a missing subnet",
+			ips:    "192.168.0.1,10.0.0.1",
+			subnet: "missing",
+			want:   "192.168.0.1",
+		},
+		{
+			name:   "link-local addresses are ignored",
+			ips:    "fe80::1,fd00:10::1",
+			subnet: "dual-stack",
+			want:   "fd00:10::1",
+		},
+		{
+			name:   "empty list",
+			ips:    "",
+			subnet: "dual-stack",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := allocatedIPs(tt.ips, tt.subnet)
+			if err != nil {
+				t.Fatalf("allocatedIPs() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("allocatedIPs() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutLinkLocalIPs(t *testing.T) {
+	tests := []struct {
+		name string
+		ips  string
+		want string
+	}{
+		{
+			name: "global addresses are kept",
+			ips:  "10.0.0.1,fd00::1",
+			want: "10.0.0.1,fd00::1",
+		},
+		{
+			name: "link-local addresses are removed",
+			ips:  "10.0.0.1,fe80::1,169.254.0.1",
+			want: "10.0.0.1",
+		},
+		{
+			name: "only link-local addresses",
+			ips:  "fe80::1",
+			want: "",
+		},
+		{
+			name: "empty list",
+			ips:  "",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withoutLinkLocalIPs(tt.ips); got != tt.want {
+				t.Errorf("withoutLinkLocalIPs() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	kvutil "kubevirt.io/kubevirt-velero-plugin/pkg/util"
+)
+
+const (
+	// kubeOvnCNIType is the type of the CNI plugin of the NADs attaching an interface to a Kube-OVN subnet
+	kubeOvnCNIType = "kube-ovn"
+	// kubeOvnProviderSuffix ends the providers of the interfaces Kube-OVN attaches to its logical switches
+	kubeOvnProviderSuffix = ".ovn"
+	// annotationDomain ends the prefix of the annotations Kube-OVN reads and writes for a provider
+	annotationDomain = ".kubernetes.io"
+)
+
+// ErrUnmanagedNetwork is returned for the Multus networks whose interfaces aren't managed by Kube-OVN, like bridge,
+// macvlan or SR-IOV attachments. Kube-OVN doesn't allocate an IP for them, so they have no identity to persist.
+var ErrUnmanagedNetwork = errors.New("network not managed by Kube-OVN")
+
+// ErrIPAMOnlyNetwork is returned for the Multus networks whose plugin only delegates its IPAM to Kube-OVN, like a
+// macvlan attachment. Kube-OVN allocates an IP for them, but the identity of their interfaces isn't persisted, so they
+// get new addresses on restore.
+var ErrIPAMOnlyNetwork = errors.New("network only delegating its IPAM to Kube-OVN is not supported")
+
+// GetNetworkClient returns a client for the NetworkAttachmentDefinitions of the cluster.
+// This is assigned to a variable so it can be replaced by a mock function in tests
+var GetNetworkClient = func() (nadclient.Interface, error) {
+	client, err := kvutil.GetKubeVirtclient()
+	if err != nil {
+		return nil, err
+	}
+
+	return (*client).NetworkClient(), nil
+}
+
+// cniConfig holds the fields of a CNI configuration, or of a CNI configuration list, relevant to Kube-OVN
+type cniConfig struct {
+	Type     string      `json:"type"`
+	Provider string      `json:"provider"`
+	IPAM     *cniConfig  `json:"ipam"`
+	Plugins  []cniConfig `json:"plugins"`
+}
+
+// ResolveNadAnnotation translates a Kubevirt NetworkName into a NAD annotation, honoring the provider configured in
+// the NAD. Kube-OVN prefixes the annotations of an interface, and names its IP, after that provider. When the NAD
+// doesn't exist, as can happen during a restore, the default [NAD].[NS].ovn provider is assumed.
+// Returns an error wrapping ErrUnmanagedNetwork if the NAD doesn't attach its interfaces through Kube-OVN, or
+// ErrIPAMOnlyNetwork if it only delegates their IPAM to Kube-OVN.
+func ResolveNadAnnotation(networkName, vmNamespace string) (string, error) {
+	nadName, nadNamespace, err := parseNetworkName(networkName, vmNamespace)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := GetNetworkClient()
+	if err != nil {
+		return "", fmt.Errorf("failed to create NetworkAttachmentDefinition clientset: %w", err)
+	}
+
+	nad, err := client.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nadNamespace).Get(context.Background(), nadName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return NetworkNameToNadAnnotation(networkName, vmNamespace)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve NAD %s/%s: %w", nadNamespace, nadName, err)
+	}
+
+	provider, err := providerOfNAD(nad)
+	if err != nil {
+		return "", err
+	}
+	if provider == "" {
+		return NetworkNameToNadAnnotation(networkName, vmNamespace)
+	}
+
+	return provider + annotationDomain, nil
+}
+
+// isDefaultProvider reports whether a provider of pattern [NAD].[NS].ovn is the default provider of the NAD it names,
+// rather than a custom provider configured in another NAD. When the NAD doesn't exist, as can happen during a restore,
+// the default provider is assumed unless another NAD of the cluster configures it.
+func isDefaultProvider(nadName, nadNamespace string) (bool, error) {
+	client, err := GetNetworkClient()
+	if err != nil {
+		return false, fmt.Errorf("failed to create NetworkAttachmentDefinition clientset: %w", err)
+	}
+	defaultProvider := fmt.Sprintf("%s.%s%s", nadName, nadNamespace, kubeOvnProviderSuffix)
+
+	nad, err := client.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nadNamespace).Get(context.Background(), nadName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		nads, err := client.K8sCniCncfIoV1().NetworkAttachmentDefinitions(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to list NADs: %w", err)
+		}
+		for i := range nads.Items {
+			if provider, err := providerOfNAD(&nads.Items[i]); err == nil && provider == defaultProvider {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve NAD %s/%s: %w", nadNamespace, nadName, err)
+	}
+
+	// A NAD not attached through Kube-OVN has no such provider, the provider then belongs to another NAD
+	provider, err := providerOfNAD(nad)
+	if errors.Is(err, ErrUnmanagedNetwork) || errors.Is(err, ErrIPAMOnlyNetwork) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return provider == "" || provider == defaultProvider, nil
+}
+
+// providerOfNAD returns the provider configured for the Kube-OVN plugin of a NAD, or an empty string if the NAD
+// relies on the default provider. Returns an error wrapping ErrUnmanagedNetwork if the NAD has no Kube-OVN plugin,
+// or ErrIPAMOnlyNetwork if its plugin only delegates its IPAM to Kube-OVN.
+func providerOfNAD(nad *nadv1.NetworkAttachmentDefinition) (string, error) {
+	// Multus reads the configuration of a NAD without one from the disk of the nodes, we cannot inspect it
+	if strings.TrimSpace(nad.Spec.Config) == "" {
+		return "", fmt.Errorf("%w: NAD %s/%s has no CNI configuration", ErrUnmanagedNetwork, nad.Namespace, nad.Name)
+	}
+
+	var config cniConfig
+	if err := json.Unmarshal([]byte(nad.Spec.Config), &config); err != nil {
+		return "", fmt.Errorf("invalid CNI configuration of NAD %s/%s: %w", nad.Namespace, nad.Name, err)
+	}
+
+	// The configuration is either a single plugin or a list of chained plugins
+	var pluginTypes []string
+	ipamOnly := ""
+	for _, plugin := range append([]cniConfig{config}, config.Plugins...) {
+		if plugin.Type == "" {
+			continue
+		}
+		pluginTypes = append(pluginTypes, plugin.Type)
+
+		if plugin.Type != kubeOvnCNIType {
+			// Kube-OVN allocates the addresses of such interfaces, but another plugin creates them
+			if plugin.IPAM != nil && plugin.IPAM.Type == kubeOvnCNIType {
+				ipamOnly = plugin.Type
+			}
+			continue
+		}
+		if plugin.Provider == "" {
+			return "", nil
+		}
+
+		if !strings.HasSuffix(plugin.Provider, kubeOvnProviderSuffix) {
+			return "", fmt.Errorf("unsupported provider %s of NAD %s/%s: expected it to end with %s", plugin.Provider, nad.Namespace, nad.Name, kubeOvnProviderSuffix)
+		}
+		return plugin.Provider, nil
+	}
+
+	if ipamOnly != "" {
+		return "", fmt.Errorf("%w: NAD %s/%s only delegates the IPAM of its %s plugin to Kube-OVN", ErrIPAMOnlyNetwork, nad.Namespace, nad.Name, ipamOnly)
+	}
+	return "", fmt.Errorf("%w: NAD %s/%s uses the %s CNI plugin", ErrUnmanagedNetwork, nad.Namespace, nad.Name, strings.Join(pluginTypes, ","))
+}
+
+// SkippedNetwork is a Multus network of a VM or VMI whose identity isn't persisted
+type SkippedNetwork struct {
+	Name   string
+	Reason string
+	// Unsupported is set for the networks Kube-OVN allocates addresses to, which then change on restore
+	Unsupported bool
+}
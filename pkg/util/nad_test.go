@@ -0,0 +1,223 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "kubevirt.io/api/core/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
+)
+
+func TestResolveNadAnnotation(t *testing.T) {
+	// Mock GetNetworkClient
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+
+	newNAD := func(namespace, name, config string) *nadv1.NetworkAttachmentDefinition {
+		return &nadv1.NetworkAttachmentDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: config},
+		}
+	}
+	// The NADs are created through the client, the fake clientset doesn't register them under the right resource otherwise
+	fakeClient := nadfake.NewSimpleClientset()
+	for _, nad := range []*nadv1.NetworkAttachmentDefinition{
+		newNAD("test-ns", "default-provider", `{"cniVersion": "0.3.0", "type": "kube-ovn", "server_socket": "/run/openvswitch/kube-ovn-daemon.sock"}`),
+		newNAD("test-ns", "custom-provider", `{"cniVersion": "0.3.0", "type": "kube-ovn", "provider": "tenant.ovn"}`),
+		newNAD("networks", "chained", `{"cniVersion": "0.3.1", "plugins": [{"type": "kube-ovn", "provider": "shared.networks.ovn"}, {"type": "portmap"}]}`),
+		newNAD("test-ns", "underlay", `{"cniVersion": "0.3.0", "type": "kube-ovn", "provider": "underlay.test-ns"}`),
+		newNAD("test-ns", "malformed", `{"type": `),
+		newNAD("test-ns", "bridge", `{"cniVersion": "0.3.1", "type": "bridge", "bridge": "br0", "ipam": {"type": "host-local"}}`),
+		newNAD("test-ns", "macvlan", `{"cniVersion": "0.3.1", "type": "macvlan", "master": "eth1", "ipam": {"type": "kube-ovn", "provider": "macvlan.test-ns"}}`),
+		newNAD("test-ns", "sriov", `{"cniVersion": "0.3.1", "plugins": [{"type": "sriov", "vlan": 100}, {"type": "tuning"}]}`),
+		newNAD("test-ns", "no-config", ``),
+	} {
+		_, _ = fakeClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nad.Namespace).Create(context.Background(), nad, metav1.CreateOptions{})
+	}
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return fakeClient, nil
+	}
+
+	tests := []struct {
+		name        string
+		networkName string
+		want        string
+		wantErr     bool
+		// wantUnmanaged expects the error to wrap ErrUnmanagedNetwork
+		wantUnmanaged bool
+		// wantIPAMOnly expects the error to wrap ErrIPAMOnlyNetwork
+		wantIPAMOnly bool
+	}{
+		{
+			name:        "default provider",
+			networkName: "test-ns/default-provider",
+			want:        "default-provider.test-ns.ovn.kubernetes.io",
+			wantErr:     false,
+		},
+		{
+			name:        "custom provider",
+			networkName: "custom-provider",
+			want:        "tenant.ovn.kubernetes.io",
+			wantErr:     false,
+		},
+		{
+			name:        "custom provider in a list of chained plugins",
+			networkName: "networks/chained",
+			want:        "shared.networks.ovn.kubernetes.io",
+			wantErr:     false,
+		},
+		{
+			name:        "missing NAD",
+			networkName: "test-ns/missing",
+			want:        "missing.test-ns.ovn.kubernetes.io",
+			wantErr:     false,
+		},
+		{
+			name:        "provider not managed by OVN",
+			networkName: "test-ns/underlay",
+			wantErr:     true,
+		},
+		{
+			name:        "malformed CNI configuration",
+			networkName: "test-ns/malformed",
+			wantErr:     true,
+		},
+		{
+			name:          "bridge plugin",
+			networkName:   "test-ns/bridge",
+			wantErr:       true,
+			wantUnmanaged: true,
+		},
+		{
+			name:         "macvlan plugin with Kube-OVN IPAM",
+			networkName:  "test-ns/macvlan",
+			wantErr:      true,
+			wantIPAMOnly: true,
+		},
+		{
+			name:          "list of chained plugins without Kube-OVN",
+			networkName:   "test-ns/sriov",
+			wantErr:       true,
+			wantUnmanaged: true,
+		},
+		{
+			name:          "NAD without CNI configuration",
+			networkName:   "test-ns/no-config",
+			wantErr:       true,
+			wantUnmanaged: true,
+		},
+		{
+			name:        "invalid network name",
+			networkName: "a/b/c",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveNadAnnotation(tt.networkName, "test-ns")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResolveNadAnnotation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if errors.Is(err, ErrUnmanagedNetwork) != tt.wantUnmanaged {
+				t.Errorf("ResolveNadAnnotation() error = %v, wantUnmanaged %v", err, tt.wantUnmanaged)
+			}
+			if errors.Is(err, ErrIPAMOnlyNetwork) != tt.wantIPAMOnly {
+				t.Errorf("ResolveNadAnnotation() error = %v, wantIPAMOnly %v", err, tt.wantIPAMOnly)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveNadAnnotation() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNadAnnotationsOfNetworks(t *testing.T) {
+	// Mock GetNetworkClient
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+
+	fakeClient := nadfake.NewSimpleClientset()
+	for _, nad := range []*nadv1.NetworkAttachmentDefinition{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ovn", Namespace: "test-ns"},
+			Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.0", "type": "kube-ovn"}`},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bridge", Namespace: "test-ns"},
+			Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.1", "type": "bridge"}`},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "macvlan", Namespace: "test-ns"},
+			Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.1", "type": "macvlan", "ipam": {"type": "kube-ovn", "provider": "macvlan.test-ns"}}`},
+		},
+	} {
+		_, _ = fakeClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nad.Namespace).Create(context.Background(), nad, metav1.CreateOptions{})
+	}
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return fakeClient, nil
+	}
+
+	multus := func(name, networkName string) v1.Network {
+		return v1.Network{Name: name, NetworkSource: v1.NetworkSource{Multus: &v1.MultusNetwork{NetworkName: networkName}}}
+	}
+
+	tests := []struct {
+		name        string
+		networks    []v1.Network
+		wantNads    []string
+		wantSkipped []SkippedNetwork
+		wantErr     bool
+	}{
+		{
+			name:        "no networks",
+			networks:    nil,
+			wantNads:    []string{"ovn.kubernetes.io"},
+			wantSkipped: nil,
+			wantErr:     false,
+		},
+		{
+			name: "mix of Kube-OVN, bridge and IPAM-only networks",
+			networks: []v1.Network{
+				{Name: "default", NetworkSource: v1.NetworkSource{Pod: &v1.PodNetwork{}}},
+				multus("ovn", "ovn"),
+				multus("bridge", "test-ns/bridge"),
+				multus("macvlan", "test-ns/macvlan"),
+				multus("missing", "test-ns/missing"),
+			},
+			wantNads: []string{"ovn.kubernetes.io", "ovn.test-ns.ovn.kubernetes.io", "missing.test-ns.ovn.kubernetes.io"},
+			wantSkipped: []SkippedNetwork{
+				{Name: "bridge", Reason: "network not managed by Kube-OVN: NAD test-ns/bridge uses the bridge CNI plugin"},
+				{Name: "macvlan", Reason: "network only delegating its IPAM to Kube-OVN is not supported: NAD test-ns/macvlan only delegates the IPAM of its macvlan plugin to Kube-OVN", Unsupported: true},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "invalid network name",
+			networks: []v1.Network{multus("invalid", "a/b/c")},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nads, skipped, err := nadAnnotationsOfNetworks(tt.networks, "test-vm", "test-ns")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("nadAnnotationsOfNetworks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(nads, tt.wantNads) {
+				t.Errorf("nadAnnotationsOfNetworks() got NADs = %v, want %v", nads, tt.wantNads)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("nadAnnotationsOfNetworks() got skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NATBindings lists the Kube-OVN objects giving public reachability to the addresses of a VM: the FIP and DNAT rules
+// targeting them, and the EIPs those rules use. Every list is sorted.
+type NATBindings struct {
+	OvnEIPs           []string
+	OvnFIPs           []string
+	OvnDNATRules      []string
+	IptablesEIPs      []string
+	IptablesFIPRules  []string
+	IptablesDNATRules []string
+}
+
+// GetNATBindings finds the FIP and DNAT rules whose internal address is one of the addresses of the interfaces of a
+// VM, either through the address itself or through the name of the IP Kube-OVN allocates to the interface, along with
+// the EIPs they use
+func GetNATBindings(netInfos []NetInfo, vmName, vmNamespace string) (*NATBindings, error) {
+	bindings := &NATBindings{}
+
+	names := make(map[string]bool)
+	addresses := make(map[string]bool)
+	for _, netInfo := range netInfos {
+		ipName, err := getIPCRNameForVM(netInfo.NADAnnotation, vmName, vmNamespace)
+		if err != nil {
+			return nil, err
+		}
+		names[ipName] = true
+
+		for _, address := range strings.Split(netInfo.IPs, ",") {
+			if parsed := net.ParseIP(address); parsed != nil {
+				addresses[parsed.String()] = true
+			}
+		}
+	}
+	if len(names) == 0 && len(addresses) == 0 {
+		return bindings, nil
+	}
+
+	// targets checks whether one of the internal addresses of a rule belongs to the VM
+	targets := func(internal ...string) bool {
+		for _, address := range internal {
+			if parsed := net.ParseIP(address); parsed != nil && addresses[parsed.String()] {
+				return true
+			}
+		}
+		return false
+	}
+
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	ovnEIPs := make(map[string]bool)
+	iptablesEIPs := make(map[string]bool)
+
+	fips, err := client.KubeovnV1().OvnFips().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OVN FIPs: %w", err)
+	}
+	for _, fip := range fips.Items {
+		if names[fip.Spec.IPName] || targets(fip.Spec.V4Ip, fip.Spec.V6Ip, fip.Status.V4Ip, fip.Status.V6Ip) {
+			bindings.OvnFIPs = append(bindings.OvnFIPs, fip.Name)
+			ovnEIPs[fip.Spec.OvnEip] = true
+		}
+	}
+
+	dnatRules, err := client.KubeovnV1().OvnDnatRules().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OVN DNAT rules: %w", err)
+	}
+	for _, rule := range dnatRules.Items {
+		if names[rule.Spec.IPName] || targets(rule.Spec.V4Ip, rule.Spec.V6Ip, rule.Status.V4Ip, rule.Status.V6Ip) {
+			bindings.OvnDNATRules = append(bindings.OvnDNATRules, rule.Name)
+			ovnEIPs[rule.Spec.OvnEip] = true
+		}
+	}
+
+	fipRules, err := client.KubeovnV1().IptablesFIPRules().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list iptables FIP rules: %w", err)
+	}
+	for _, rule := range fipRules.Items {
+		if targets(rule.Spec.InternalIP, rule.Status.InternalIP) {
+			bindings.IptablesFIPRules = append(bindings.IptablesFIPRules, rule.Name)
+			iptablesEIPs[rule.Spec.EIP] = true
+		}
+	}
+
+	iptablesDnatRules, err := client.KubeovnV1().IptablesDnatRules().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list iptables DNAT rules: %w", err)
+	}
+	for _, rule := range iptablesDnatRules.Items {
+		if targets(rule.Spec.InternalIP, rule.Status.InternalIP) {
+			bindings.IptablesDNATRules = append(bindings.IptablesDNATRules, rule.Name)
+			iptablesEIPs[rule.Spec.EIP] = true
+		}
+	}
+
+	bindings.OvnEIPs = sortedNames(ovnEIPs)
+	bindings.IptablesEIPs = sortedNames(iptablesEIPs)
+	sort.Strings(bindings.OvnFIPs)
+	sort.Strings(bindings.OvnDNATRules)
+	sort.Strings(bindings.IptablesFIPRules)
+	sort.Strings(bindings.IptablesDNATRules)
+
+	return bindings, nil
+}
+
+// RemapIPName rewrites the namespaces of the name of a Kube-OVN IP allocated to a VM according to a namespace
+// mapping. Such names follow the pattern [VM].[NS] for the default network and [VM].[NS].[PROVIDER] for NADs, where
+// only the default [NAD].[NAD NS].ovn providers embed a namespace. Other names are returned unchanged.
+func RemapIPName(ipName string, namespaceMapping map[string]string) (string, error) {
+	// VM names may contain dots, and so may providers, so the name is split on the first mapped namespace
+	// that the rest of the name follows as a provider
+	labels := strings.Split(ipName, ".")
+	vmNamespace := -1
+	for i := 1; i < len(labels); i++ {
+		if _, ok := namespaceMapping[labels[i]]; ok && (i == len(labels)-1 || isProvider(labels[i+1:])) {
+			vmNamespace = i
+			break
+		}
+	}
+
+	switch {
+	case vmNamespace != -1:
+		labels[vmNamespace] = namespaceMapping[labels[vmNamespace]]
+	case len(labels) >= 5 && isProvider(labels[len(labels)-3:]):
+		// The VM stays in its namespace, only a default provider may reference a NAD in a mapped namespace
+		vmNamespace = len(labels) - 4
+	default:
+		return ipName, nil
+	}
+
+	if vmNamespace == len(labels)-1 {
+		return strings.Join(labels, "."), nil
+	}
+
+	provider, err := remapProvider(strings.Join(labels[vmNamespace+1:], "."), namespaceMapping)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(append(labels[:vmNamespace+1], provider), "."), nil
+}
+
+// isProvider reports whether the labels of a name form a Kube-OVN provider
+func isProvider(labels []string) bool {
+	if len(labels) < 2 || "."+labels[len(labels)-1] != kubeOvnProviderSuffix {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortedNames returns the non-empty keys of a set, sorted
+func sortedNames(set map[string]bool) []string {
+	var names []string
+	for name := range set {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,131 @@
+package util
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	nadclient "kubevirt.io/client-go/networkattachmentdefinitionclient"
+	nadfake "kubevirt.io/client-go/networkattachmentdefinitionclient/fake"
+)
+
+func TestGetNATBindings(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	fakeClient := fake.NewSimpleClientset()
+	for _, fip := range []*kubeovnv1.OvnFip{
+		{ObjectMeta: metav1.ObjectMeta{Name: "fip-by-name"}, Spec: kubeovnv1.OvnFipSpec{OvnEip: "eip-a", IPName: "test-vm.test-ns"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "fip-other"}, Spec: kubeovnv1.OvnFipSpec{OvnEip: "eip-b", V4Ip: "10.0.0.9"}},
+	} {
+		_, _ = fakeClient.KubeovnV1().OvnFips().Create(context.Background(), fip, metav1.CreateOptions{})
+	}
+	_, _ = fakeClient.KubeovnV1().OvnDnatRules().Create(context.Background(), &kubeovnv1.OvnDnatRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "dnat-by-address"},
+		Spec:       kubeovnv1.OvnDnatRuleSpec{OvnEip: "eip-a", V6Ip: "fd00::0001"},
+	}, metav1.CreateOptions{})
+	_, _ = fakeClient.KubeovnV1().IptablesFIPRules().Create(context.Background(), &kubeovnv1.IptablesFIPRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "iptables-fip"},
+		Spec:       kubeovnv1.IptablesFIPRuleSpec{EIP: "iptables-eip", InternalIP: "10.0.0.1"},
+	}, metav1.CreateOptions{})
+	_, _ = fakeClient.KubeovnV1().IptablesDnatRules().Create(context.Background(), &kubeovnv1.IptablesDnatRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "iptables-dnat-other"},
+		Spec:       kubeovnv1.IptablesDnatRuleSpec{EIP: "iptables-eip-other", InternalIP: "10.0.0.9"},
+	}, metav1.CreateOptions{})
+	GetKubeOvnClient = func() (KubeOvnClient, error) {
+		return fakeClient, nil
+	}
+
+	tests := []struct {
+		name     string
+		netInfos []NetInfo
+		want     *NATBindings
+	}{
+		{
+			name: "rules bound by name and by address",
+			netInfos: []NetInfo{
+				{NADAnnotation: "ovn.kubernetes.io", IPs: "10.0.0.1,fd00::1"},
+			},
+			want: &NATBindings{
+				OvnEIPs:          []string{"eip-a"},
+				OvnFIPs:          []string{"fip-by-name"},
+				OvnDNATRules:     []string{"dnat-by-address"},
+				IptablesEIPs:     []string{"iptables-eip"},
+				IptablesFIPRules: []string{"iptables-fip"},
+			},
+		},
+		{
+			name: "no binding",
+			netInfos: []NetInfo{
+				{NADAnnotation: "test-nad.test-ns.ovn.kubernetes.io", IPs: "10.0.0.2"},
+			},
+			want: &NATBindings{},
+		},
+		{
+			name: "no interface",
+			want: &NATBindings{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetNATBindings(tt.netInfos, "test-vm", "test-ns")
+			if err != nil {
+				t.Fatalf("GetNATBindings() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetNATBindings() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemapIPName(t *testing.T) {
+	// Mock GetNetworkClient, nad2 configures a provider looking like the default one of another NAD
+	originalGetNetworkClient := GetNetworkClient
+	defer func() { GetNetworkClient = originalGetNetworkClient }()
+	nadClient := nadfake.NewSimpleClientset()
+	_, _ = nadClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions("nad-ns").Create(context.Background(), &nadv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "nad2", Namespace: "nad-ns"},
+		Spec:       nadv1.NetworkAttachmentDefinitionSpec{Config: `{"cniVersion": "0.3.0", "type": "kube-ovn", "provider": "shared.nad-ns.ovn"}`},
+	}, metav1.CreateOptions{})
+	GetNetworkClient = func() (nadclient.Interface, error) {
+		return nadClient, nil
+	}
+
+	mapping := map[string]string{"test-ns": "other-ns", "nad-ns": "other-nad-ns"}
+
+	tests := []struct {
+		name   string
+		ipName string
+		want   string
+	}{
+		{name: "default network", ipName: "test-vm.test-ns", want: "test-vm.other-ns"},
+		{name: "NAD network", ipName: "test-vm.test-ns.nad1.nad-ns.ovn", want: "test-vm.other-ns.nad1.other-nad-ns.ovn"},
+		{name: "NAD network of a VM in an unmapped namespace", ipName: "test-vm.kept-ns.nad1.nad-ns.ovn", want: "test-vm.kept-ns.nad1.other-nad-ns.ovn"},
+		{name: "custom provider", ipName: "test-vm.test-ns.custom.ovn", want: "test-vm.other-ns.custom.ovn"},
+		{name: "custom provider with a namespace", ipName: "test-vm.test-ns.tenant.nad-ns.custom.ovn", want: "test-vm.other-ns.tenant.nad-ns.custom.ovn"},
+		{name: "custom provider looking like a default one", ipName: "test-vm.test-ns.shared.nad-ns.ovn", want: "test-vm.other-ns.shared.nad-ns.ovn"},
+		{name: "VM name with dots", ipName: "test.vm.test-ns", want: "test.vm.other-ns"},
+		{name: "VM name with dots and a NAD network", ipName: "test.vm.test-ns.nad1.nad-ns.ovn", want: "test.vm.other-ns.nad1.other-nad-ns.ovn"},
+		{name: "unmapped namespace", ipName: "test-vm.kept-ns", want: "test-vm.kept-ns"},
+		{name: "not an IP name", ipName: "standalone", want: "standalone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemapIPName(tt.ipName, mapping)
+			if err != nil {
+				t.Fatalf("RemapIPName() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RemapIPName() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
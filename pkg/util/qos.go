@@ -0,0 +1,48 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetQoSPolicies returns the sorted names of the Kube-OVN QoS policies bound to VPC NAT gateways and iptables EIPs.
+// Kube-OVN enforces the bandwidth tiers of the public addresses of the VMs through them. Missing objects are skipped.
+func GetQoSPolicies(natGateways, iptablesEIPs []string) ([]string, error) {
+	if len(natGateways) == 0 && len(iptablesEIPs) == 0 {
+		return nil, nil
+	}
+
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	policies := make(map[string]bool)
+
+	for _, name := range natGateways {
+		natGateway, err := client.KubeovnV1().VpcNatGateways().Get(context.Background(), name, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve VPC NAT gateway %s: %w", name, err)
+		}
+		policies[natGateway.Spec.QoSPolicy] = true
+	}
+
+	for _, name := range iptablesEIPs {
+		eip, err := client.KubeovnV1().IptablesEIPs().Get(context.Background(), name, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve iptables EIP %s: %w", name, err)
+		}
+		policies[eip.Spec.QoSPolicy] = true
+	}
+
+	return sortedNames(policies), nil
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetQoSPolicies(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	fakeClient := fake.NewSimpleClientset()
+	for _, natGateway := range []*kubeovnv1.VpcNatGateway{
+		{ObjectMeta: metav1.ObjectMeta{Name: "gw-limited"}, Spec: kubeovnv1.VpcNatGatewaySpec{QoSPolicy: "gw-tier"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "gw-unlimited"}},
+	} {
+		_, _ = fakeClient.KubeovnV1().VpcNatGateways().Create(context.Background(), natGateway, metav1.CreateOptions{})
+	}
+	for _, eip := range []*kubeovnv1.IptablesEIP{
+		{ObjectMeta: metav1.ObjectMeta{Name: "eip-gold"}, Spec: kubeovnv1.IptablesEIPSpec{QoSPolicy: "gold"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "eip-shared"}, Spec: kubeovnv1.IptablesEIPSpec{QoSPolicy: "gw-tier"}},
+	} {
+		_, _ = fakeClient.KubeovnV1().IptablesEIPs().Create(context.Background(), eip, metav1.CreateOptions{})
+	}
+	GetKubeOvnClient = func() (KubeOvnClient, error) {
+		return fakeClient, nil
+	}
+
+	tests := []struct {
+		name         string
+		natGateways  []string
+		iptablesEIPs []string
+		want         []string
+	}{
+		{
+			name:         "policies of NAT gateways and EIPs",
+			natGateways:  []string{"gw-limited", "gw-unlimited"},
+			iptablesEIPs: []string{"eip-gold", "eip-shared"},
+			want:         []string{"gold", "gw-tier"},
+		},
+		{
+			name:         "missing objects",
+			natGateways:  []string{"gw-missing"},
+			iptablesEIPs: []string{"eip-missing"},
+			want:         nil,
+		},
+		{
+			name: "nothing to look up",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetQoSPolicies(tt.natGateways, tt.iptablesEIPs)
+			if err != nil {
+				t.Fatalf("GetQoSPolicies() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetQoSPolicies() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	v1 "kubevirt.io/api/core/v1"
+	kvutil "kubevirt.io/kubevirt-velero-plugin/pkg/util"
+)
+
+// VerificationStatus describes how the live identity of an interface compares to its persisted identity
+type VerificationStatus string
+
+const (
+	// VerificationMatched means the interface got its persisted identity back
+	VerificationMatched VerificationStatus = "Matched"
+	// VerificationIPDrifted means Kube-OVN allocated other IP addresses to the interface.
+	// It takes precedence over VerificationMACDrifted when both drifted, the live identity is part of the report anyway.
+	VerificationIPDrifted VerificationStatus = "IPDrifted"
+	// VerificationMACDrifted means Kube-OVN allocated another MAC address to the interface
+	VerificationMACDrifted VerificationStatus = "MACDrifted"
+	// VerificationMissing means Kube-OVN has no IP custom resource for the interface
+	VerificationMissing VerificationStatus = "Missing"
+)
+
+// InterfaceVerification compares the persisted and live identity of an interface
+type InterfaceVerification struct {
+	Network     string             `json:"network"`
+	Status      VerificationStatus `json:"status"`
+	ExpectedMAC string             `json:"expectedMAC,omitempty"`
+	ExpectedIPs string             `json:"expectedIPs,omitempty"`
+	LiveMAC     string             `json:"liveMAC,omitempty"`
+	LiveIPs     string             `json:"liveIPs,omitempty"`
+}
+
+// VerificationReport compares the persisted and live identity of every interface of a VM
+type VerificationReport struct {
+	Name       string                  `json:"name"`
+	Namespace  string                  `json:"namespace"`
+	VerifiedAt metav1.Time             `json:"verifiedAt"`
+	Interfaces []InterfaceVerification `json:"interfaces"`
+}
+
+// GetKubeClient returns a client for the core resources of the cluster.
+// This is assigned to a variable so it can be replaced by a mock function in tests
+var GetKubeClient = func() (kubernetes.Interface, error) {
+	client, err := kvutil.GetKubeVirtclient()
+	if err != nil {
+		return nil, err
+	}
+
+	return *client, nil
+}
+
+// VerifyVMNetwork compares the identity persisted in the template annotations of a VM with the IP custom resources
+// Kube-OVN allocated to its interfaces. Only the parts of the persisted identity that are present are compared.
+func VerifyVMNetwork(vm *v1.VirtualMachine) (*VerificationReport, error) {
+	report := &VerificationReport{
+		Name:       vm.Name,
+		Namespace:  vm.Namespace,
+		VerifiedAt: metav1.NewTime(time.Now()),
+		Interfaces: []InterfaceVerification{},
+	}
+
+	for _, expected := range NetInfosFromAnnotations(vm.Spec.Template.ObjectMeta.Annotations) {
+		verification := InterfaceVerification{
+			Network:     expected.NADAnnotation,
+			ExpectedMAC: expected.MAC,
+			ExpectedIPs: expected.IPs,
+		}
+
+		ip, err := GetIPForVM(expected.NADAnnotation, vm.Name, vm.Namespace)
+		switch {
+		case apierrors.IsNotFound(err):
+			verification.Status = VerificationMissing
+		case err != nil:
+			return nil, err
+		default:
+			live := IPToNetInfo(expected.NADAnnotation, *ip)
+			verification.LiveMAC = live.MAC
+			verification.LiveIPs = live.IPs
+			verification.Status = verificationStatus(&expected, live)
+		}
+
+		report.Interfaces = append(report.Interfaces, verification)
+	}
+
+	return report, nil
+}
+
+// verificationStatus compares the persisted identity of an interface with the identity allocated by Kube-OVN
+func verificationStatus(expected, live *NetInfo) VerificationStatus {
+	if expected.IPs != "" && !expected.IPsMatch(live) {
+		return VerificationIPDrifted
+	}
+	if expected.MAC != "" && !expected.MACMatches(live) {
+		return VerificationMACDrifted
+	}
+
+	return VerificationMatched
+}
+
+// Matched checks whether every interface of the VM got its persisted identity back
+func (r *VerificationReport) Matched() bool {
+	for _, verification := range r.Interfaces {
+		if verification.Status != VerificationMatched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// StoreVerificationReport stores a report in a ConfigMap, under a [NS].[VM] key, creating the ConfigMap if needed.
+// The ConfigMap can be shared by every VM of a restore, so each report only updates its own key.
+func StoreVerificationReport(namespace, name string, labels map[string]string, report *VerificationReport) error {
+	client, err := GetKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode verification report of VM %s/%s: %w", report.Namespace, report.Name, err)
+	}
+	key := fmt.Sprintf("%s.%s", report.Namespace, report.Name)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := client.CoreV1().ConfigMaps(namespace)
+
+		configMap, err := configMaps.Get(context.Background(), name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Labels:    labels,
+				},
+				Data: map[string]string{key: string(data)},
+			}
+			_, err = configMaps.Create(context.Background(), configMap, metav1.CreateOptions{})
+			// Another report created the ConfigMap in the meantime, retry as an update
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[key] = string(data)
+		_, err = configMaps.Update(context.Background(), configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,202 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	v1 "kubevirt.io/api/core/v1"
+)
+
+func TestVerifyVMNetwork(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		existingIPs []*kubeovnv1.IP
+		want        []InterfaceVerification
+		wantMatched bool
+	}{
+		{
+			name: "matched identity",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":  "10.0.0.1",
+				"ovn.kubernetes.io/mac_address": "00:00:00:00:00:01",
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns"},
+					Spec:       kubeovnv1.IPSpec{V4IPAddress: "10.0.0.1", MacAddress: "00:00:00:00:00:01"},
+				},
+			},
+			want: []InterfaceVerification{
+				{Network: "ovn.kubernetes.io", Status: VerificationMatched, ExpectedMAC: "00:00:00:00:00:01", ExpectedIPs: "10.0.0.1", LiveMAC: "00:00:00:00:00:01", LiveIPs: "10.0.0.1"},
+			},
+			wantMatched: true,
+		},
+		{
+			name: "drifted and missing identities",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address":                          "10.0.0.1",
+				"ovn.kubernetes.io/mac_address":                         "00:00:00:00:00:01",
+				"nad1.test-ns.ovn.kubernetes.io/ip_address":             "10.0.1.1",
+				"nad1.test-ns.ovn.kubernetes.io/mac_address":            "00:00:00:00:00:02",
+				"nad2.test-ns.ovn.kubernetes.io/ip_address":             "10.0.2.1",
+				"nad2.test-ns.ovn.kubernetes.io/mac_address":            "00:00:00:00:00:03",
+				"existing.annotation":                                   "preserved",
+				"unrelated.ovn.kubernetes.io/logical_switch":            "ovn-default",
+				"nad3.test-ns.ovn.kubernetes.io/unsupported_annotation": "ignored",
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns"},
+					Spec:       kubeovnv1.IPSpec{V4IPAddress: "10.0.0.9", MacAddress: "00:00:00:00:00:01"},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns.nad1.test-ns.ovn"},
+					Spec:       kubeovnv1.IPSpec{V4IPAddress: "10.0.1.1", MacAddress: "00:00:00:00:00:09"},
+				},
+			},
+			want: []InterfaceVerification{
+				{Network: "nad1.test-ns.ovn.kubernetes.io", Status: VerificationMACDrifted, ExpectedMAC: "00:00:00:00:00:02", ExpectedIPs: "10.0.1.1", LiveMAC: "00:00:00:00:00:09", LiveIPs: "10.0.1.1"},
+				{Network: "nad2.test-ns.ovn.kubernetes.io", Status: VerificationMissing, ExpectedMAC: "00:00:00:00:00:03", ExpectedIPs: "10.0.2.1"},
+				{Network: "ovn.kubernetes.io", Status: VerificationIPDrifted, ExpectedMAC: "00:00:00:00:00:01", ExpectedIPs: "10.0.0.1", LiveMAC: "00:00:00:00:00:01", LiveIPs: "10.0.0.9"},
+			},
+			wantMatched: false,
+		},
+		{
+			name: "MAC address dropped during the restore",
+			annotations: map[string]string{
+				"ovn.kubernetes.io/ip_address": "10.0.0.1",
+			},
+			existingIPs: []*kubeovnv1.IP{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-vm.test-ns"},
+					Spec:       kubeovnv1.IPSpec{V4IPAddress: "10.0.0.1", MacAddress: "00:00:00:00:00:09"},
+				},
+			},
+			want: []InterfaceVerification{
+				{Network: "ovn.kubernetes.io", Status: VerificationMatched, ExpectedIPs: "10.0.0.1", LiveMAC: "00:00:00:00:00:09", LiveIPs: "10.0.0.1"},
+			},
+			wantMatched: true,
+		},
+		{
+			name:        "no persisted identity",
+			annotations: map[string]string{"existing.annotation": "preserved"},
+			want:        []InterfaceVerification{},
+			wantMatched: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up fake Kube-OVN client
+			fakeClient := fake.NewSimpleClientset()
+			for _, ip := range tt.existingIPs {
+				_, _ = fakeClient.KubeovnV1().IPs().Create(context.Background(), ip, metav1.CreateOptions{})
+			}
+			GetKubeOvnClient = func() (KubeOvnClient, error) {
+				return fakeClient, nil
+			}
+
+			vm := &v1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-ns"},
+				Spec: v1.VirtualMachineSpec{
+					Template: &v1.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+					},
+				},
+			}
+
+			got, err := VerifyVMNetwork(vm)
+			if err != nil {
+				t.Fatalf("VerifyVMNetwork() unexpected error = %v", err)
+			}
+			if got.Name != "test-vm" || got.Namespace != "test-ns" {
+				t.Errorf("VerifyVMNetwork() got report for %s/%s, want test-ns/test-vm", got.Namespace, got.Name)
+			}
+			if len(got.Interfaces) != len(tt.want) {
+				t.Fatalf("VerifyVMNetwork() got %v, want %v", got.Interfaces, tt.want)
+			}
+			for i := range tt.want {
+				if got.Interfaces[i] != tt.want[i] {
+					t.Errorf("VerifyVMNetwork() got[%d] = %+v, want %+v", i, got.Interfaces[i], tt.want[i])
+				}
+			}
+			if got.Matched() != tt.wantMatched {
+				t.Errorf("Matched() got = %v, want %v", got.Matched(), tt.wantMatched)
+			}
+		})
+	}
+}
+
+func TestStoreVerificationReport(t *testing.T) {
+	// Mock GetKubeClient
+	originalGetKubeClient := GetKubeClient
+	defer func() { GetKubeClient = originalGetKubeClient }()
+
+	fakeClient := kubefake.NewSimpleClientset()
+	GetKubeClient = func() (kubernetes.Interface, error) {
+		return fakeClient, nil
+	}
+
+	labels := map[string]string{"velero.io/restore-name": "test-restore"}
+	reports := []*VerificationReport{
+		{
+			Name:       "vm1",
+			Namespace:  "test-ns",
+			Interfaces: []InterfaceVerification{{Network: "ovn.kubernetes.io", Status: VerificationMatched}},
+		},
+		{
+			Name:       "vm2",
+			Namespace:  "test-ns",
+			Interfaces: []InterfaceVerification{{Network: "ovn.kubernetes.io", Status: VerificationMissing}},
+		},
+	}
+
+	// The first report creates the ConfigMap, the second one adds its own key
+	for _, report := range reports {
+		if err := StoreVerificationReport("velero", "test-report", labels, report); err != nil {
+			t.Fatalf("StoreVerificationReport() unexpected error = %v", err)
+		}
+	}
+
+	configMap, err := fakeClient.CoreV1().ConfigMaps("velero").Get(context.Background(), "test-report", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get ConfigMap: %v", err)
+	}
+	if configMap.Labels["velero.io/restore-name"] != "test-restore" {
+		t.Errorf("StoreVerificationReport() got labels %v, want %v", configMap.Labels, labels)
+	}
+
+	for key, want := range map[string]VerificationStatus{"test-ns.vm1": VerificationMatched, "test-ns.vm2": VerificationMissing} {
+		var report VerificationReport
+		if err := json.Unmarshal([]byte(configMap.Data[key]), &report); err != nil {
+			t.Fatalf("failed to decode report %s: %v", key, err)
+		}
+		if len(report.Interfaces) != 1 || report.Interfaces[0].Status != want {
+			t.Errorf("StoreVerificationReport() got report %s = %+v, want status %s", key, report, want)
+		}
+	}
+
+	// Storing the report of a VM again replaces it
+	reports[1].Interfaces[0].Status = VerificationMatched
+	if err := StoreVerificationReport("velero", "test-report", labels, reports[1]); err != nil {
+		t.Fatalf("StoreVerificationReport() unexpected error = %v", err)
+	}
+	configMap, _ = fakeClient.CoreV1().ConfigMaps("velero").Get(context.Background(), "test-report", metav1.GetOptions{})
+	var report VerificationReport
+	_ = json.Unmarshal([]byte(configMap.Data["test-ns.vm2"]), &report)
+	if len(configMap.Data) != 2 || report.Interfaces[0].Status != VerificationMatched {
+		t.Errorf("StoreVerificationReport() got data %v", configMap.Data)
+	}
+
+}
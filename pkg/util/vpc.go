@@ -0,0 +1,84 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultVpc is the VPC Kube-OVN creates in every cluster, and uses for the subnets that don't name a VPC
+const defaultVpc = "ovn-cluster"
+
+// VPCTopology lists the Kube-OVN objects making up the custom VPCs of a set of subnets.
+// The static and policy routes of a VPC are part of its spec, they come along with it.
+type VPCTopology struct {
+	VPCs        []string
+	NATGateways []string
+	// Subnets the NAT gateways are attached to, besides the subnets the topology was built from
+	Subnets []string
+}
+
+// GetVPCTopology follows the custom VPCs of a set of subnets, along with their NAT gateways.
+// Subnets of the default VPC, and subnets that don't exist anymore, don't contribute to the topology.
+func GetVPCTopology(subnets []string) (*VPCTopology, error) {
+	topology := &VPCTopology{}
+	if len(subnets) == 0 {
+		return topology, nil
+	}
+
+	client, err := GetKubeOvnClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kube-OVN clientset: %w", err)
+	}
+
+	known := make(map[string]bool)
+	vpcs := make(map[string]bool)
+	for _, name := range subnets {
+		known[name] = true
+
+		subnet, err := client.KubeovnV1().Subnets().Get(context.Background(), name, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve subnet %s: %w", name, err)
+		}
+
+		if vpc := subnet.Spec.Vpc; vpc != "" && vpc != defaultVpc && !vpcs[vpc] {
+			vpcs[vpc] = true
+			topology.VPCs = append(topology.VPCs, vpc)
+		}
+	}
+	if len(topology.VPCs) == 0 {
+		return topology, nil
+	}
+
+	gateways, err := client.KubeovnV1().VpcNatGateways().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VPC NAT gateways: %w", err)
+	}
+
+	for _, gateway := range gateways.Items {
+		if !vpcs[gateway.Spec.Vpc] {
+			continue
+		}
+		topology.NATGateways = append(topology.NATGateways, gateway.Name)
+
+		// A gateway cannot be recreated without the subnets it is attached to
+		for _, subnet := range append([]string{gateway.Spec.Subnet}, gateway.Spec.ExternalSubnets...) {
+			if subnet != "" && !known[subnet] {
+				known[subnet] = true
+				topology.Subnets = append(topology.Subnets, subnet)
+			}
+		}
+	}
+
+	sort.Strings(topology.VPCs)
+	sort.Strings(topology.NATGateways)
+	sort.Strings(topology.Subnets)
+
+	return topology, nil
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kubeovnv1 "github.com/kubeovn/kube-ovn/pkg/apis/kubeovn/v1"
+	"github.com/kubeovn/kube-ovn/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetVPCTopology(t *testing.T) {
+	// Mock GetKubeOvnClient
+	originalGetKubeOvnClient := GetKubeOvnClient
+	defer func() { GetKubeOvnClient = originalGetKubeOvnClient }()
+
+	fakeClient := fake.NewSimpleClientset()
+	for _, subnet := range []*kubeovnv1.Subnet{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ovn-default"}, Spec: kubeovnv1.SubnetSpec{Vpc: "ovn-cluster"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "legacy-subnet"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a-subnet"}, Spec: kubeovnv1.SubnetSpec{Vpc: "tenant-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a-other"}, Spec: kubeovnv1.SubnetSpec{Vpc: "tenant-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b-subnet"}, Spec: kubeovnv1.SubnetSpec{Vpc: "tenant-b"}},
+	} {
+		_, _ = fakeClient.KubeovnV1().Subnets().Create(context.Background(), subnet, metav1.CreateOptions{})
+	}
+	for _, gateway := range []*kubeovnv1.VpcNatGateway{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-a-gw"},
+			Spec:       kubeovnv1.VpcNatGatewaySpec{Vpc: "tenant-a", Subnet: "tenant-a-subnet", ExternalSubnets: []string{"external"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-b-gw"},
+			Spec:       kubeovnv1.VpcNatGatewaySpec{Vpc: "tenant-b", Subnet: "tenant-b-subnet"},
+		},
+	} {
+		_, _ = fakeClient.KubeovnV1().VpcNatGateways().Create(context.Background(), gateway, metav1.CreateOptions{})
+	}
+	GetKubeOvnClient = func() (KubeOvnClient, error) {
+		return fakeClient, nil
+	}
+
+	tests := []struct {
+		name    string
+		subnets []string
+		want    *VPCTopology
+	}{
+		{
+			name:    "subnets of the default VPC",
+			subnets: []string{"ovn-default", "legacy-subnet"},
+			want:    &VPCTopology{},
+		},
+		{
+			name:    "subnets of a custom VPC",
+			subnets: []string{"ovn-default", "tenant-a-other", "tenant-a-subnet"},
+			want: &VPCTopology{
+				VPCs:        []string{"tenant-a"},
+				NATGateways: []string{"tenant-a-gw"},
+				Subnets:     []string{"external"},
+			},
+		},
+		{
+			name:    "subnets of several custom VPCs",
+			subnets: []string{"tenant-a-other", "tenant-b-subnet"},
+			want: &VPCTopology{
+				VPCs:        []string{"tenant-a", "tenant-b"},
+				NATGateways: []string{"tenant-a-gw", "tenant-b-gw"},
+				Subnets:     []string{"external", "tenant-a-subnet"},
+			},
+		},
+		{
+			name:    "missing subnet",
+			subnets: []string{"deleted-subnet"},
+			want:    &VPCTopology{},
+		},
+		{
+			name:    "no subnets",
+			subnets: nil,
+			want:    &VPCTopology{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetVPCTopology(tt.subnets)
+			if err != nil {
+				t.Fatalf("GetVPCTopology() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetVPCTopology() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}